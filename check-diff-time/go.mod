@@ -4,8 +4,8 @@ go 1.20
 
 require (
 	github.com/jessevdk/go-flags v1.5.0
+	github.com/kevinburke/ssh_config v1.6.0
 	github.com/mackerelio/checkers v0.0.4
 	golang.org/x/crypto v0.8.0
+	golang.org/x/sys v0.7.0
 )
-
-require golang.org/x/sys v0.7.0 // indirect
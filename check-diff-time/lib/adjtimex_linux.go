@@ -0,0 +1,42 @@
+//go:build linux
+
+package checkdifftime
+
+import (
+	"fmt"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+var clockStates = map[int]string{
+	unix.TIME_OK:    "TIME_OK",
+	unix.TIME_INS:   "TIME_INS",
+	unix.TIME_DEL:   "TIME_DEL",
+	unix.TIME_OOP:   "TIME_OOP",
+	unix.TIME_WAIT:  "TIME_WAIT",
+	unix.TIME_ERROR: "TIME_ERROR",
+}
+
+// readLocalClock calls adjtimex without modes, which only reads the kernel
+// state and needs no privilege.
+func readLocalClock() (*localClock, error) {
+	var timex unix.Timex
+	state, err := unix.Adjtimex(&timex)
+	if err != nil {
+		return nil, fmt.Errorf("adjtimex: %s", err)
+	}
+
+	name, ok := clockStates[state]
+	if !ok {
+		name = fmt.Sprintf("state %d", state)
+	}
+	return &localClock{
+		synchronized:   timex.Status&unix.STA_UNSYNC == 0 && state != unix.TIME_ERROR,
+		state:          name,
+		estimatedError: time.Duration(timex.Esterror) * time.Microsecond,
+		maximumError:   time.Duration(timex.Maxerror) * time.Microsecond,
+		// freq is in ppm with a 16 bit fractional part
+		frequency: float64(timex.Freq) / 65536,
+	}, nil
+}
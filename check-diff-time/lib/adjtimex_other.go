@@ -0,0 +1,9 @@
+//go:build !linux
+
+package checkdifftime
+
+import "errors"
+
+func readLocalClock() (*localClock, error) {
+	return nil, errors.New("local mode is only supported on Linux")
+}
@@ -0,0 +1,204 @@
+package checkdifftime
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// sshAuth holds the authentication methods offered to the server, in this
+// order: identity certificate, identity key, ssh-agent keys, password.
+// It remembers which one the server accepted.
+type sshAuth struct {
+	methods []ssh.AuthMethod
+	used    string
+	agent   net.Conn
+	// agentSkipped is why the ssh-agent of $SSH_AUTH_SOCK was left out
+	agentSkipped error
+}
+
+type labelledSigner struct {
+	ssh.Signer
+	label string
+	auth  *sshAuth
+}
+
+func (s *labelledSigner) Sign(rand io.Reader, data []byte) (*ssh.Signature, error) {
+	s.auth.used = s.label
+	return s.Signer.Sign(rand, data)
+}
+
+type labelledAlgorithmSigner struct {
+	labelledSigner
+	algorithmSigner ssh.AlgorithmSigner
+}
+
+func (s *labelledAlgorithmSigner) SignWithAlgorithm(rand io.Reader, data []byte, algorithm string) (*ssh.Signature, error) {
+	s.auth.used = s.label
+	return s.algorithmSigner.SignWithAlgorithm(rand, data, algorithm)
+}
+
+func (a *sshAuth) label(signer ssh.Signer, label string) ssh.Signer {
+	wrapped := labelledSigner{Signer: signer, label: label, auth: a}
+	if algorithmSigner, ok := signer.(ssh.AlgorithmSigner); ok {
+		return &labelledAlgorithmSigner{labelledSigner: wrapped, algorithmSigner: algorithmSigner}
+	}
+	return &wrapped
+}
+
+// Used returns a description of the method the server accepted.
+func (a *sshAuth) Used() string {
+	if a.used == "" {
+		return "none"
+	}
+	return a.used
+}
+
+// Close releases the ssh-agent connection, if any.
+func (a *sshAuth) Close() {
+	if a.agent != nil {
+		a.agent.Close()
+	}
+}
+
+func (opts *sshOpts) certificateFile(identity string) (string, bool) {
+	if opts.Certificate != "" {
+		return opts.Certificate, true
+	}
+	return identity + "-cert.pub", false
+}
+
+func loadCertificate(file string) (*ssh.Certificate, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	pub, _, _, _, err := ssh.ParseAuthorizedKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", file, err)
+	}
+
+	cert, ok := pub.(*ssh.Certificate)
+	if !ok {
+		return nil, fmt.Errorf("%s: not an OpenSSH certificate", file)
+	}
+	if cert.CertType != ssh.UserCert {
+		return nil, fmt.Errorf("%s: not a user certificate", file)
+	}
+	return cert, nil
+}
+
+func certificateExpired(cert *ssh.Certificate) bool {
+	now := uint64(time.Now().Unix())
+	return now < cert.ValidAfter || (cert.ValidBefore != ssh.CertTimeInfinity && now >= cert.ValidBefore)
+}
+
+func (opts *sshOpts) identitySigners(auth *sshAuth, identity string) ([]ssh.Signer, error) {
+	if identity == "" {
+		return nil, nil
+	}
+
+	signer, err := readPrivateKey(identity, opts.PassPhrase)
+	if err != nil {
+		return nil, err
+	}
+
+	signers := make([]ssh.Signer, 0, 2)
+
+	certFile, explicit := opts.certificateFile(identity)
+	cert, err := loadCertificate(certFile)
+	switch {
+	case err != nil && (explicit || !os.IsNotExist(err)):
+		return nil, err
+	case err == nil && certificateExpired(cert):
+		if explicit {
+			return nil, fmt.Errorf("%s: certificate is not valid now", certFile)
+		}
+	case err == nil:
+		certSigner, err := ssh.NewCertSigner(cert, signer)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", certFile, err)
+		}
+		signers = append(signers, auth.label(certSigner, "publickey (certificate "+certFile+")"))
+	}
+
+	signers = append(signers, auth.label(signer, "publickey (identity "+identity+")"))
+	return signers, nil
+}
+
+func (opts *sshOpts) agentSigners(auth *sshAuth) ([]ssh.Signer, error) {
+	if opts.NoAgent || opts.AgentSocket == "" {
+		return nil, nil
+	}
+
+	// a stale $SSH_AUTH_SOCK must not prevent the other methods, an
+	// explicit --agent-socket must work
+	skip := func(err error) ([]ssh.Signer, error) {
+		if opts.agentSocketSet {
+			return nil, err
+		}
+		auth.agentSkipped = err
+		return nil, nil
+	}
+
+	conn, err := net.Dial("unix", opts.AgentSocket)
+	if err != nil {
+		return skip(fmt.Errorf("cannot connect to ssh-agent: %s", err))
+	}
+	auth.agent = conn
+
+	client := agent.NewClient(conn)
+	keys, err := client.List()
+	if err != nil {
+		return skip(fmt.Errorf("cannot list ssh-agent keys: %s", err))
+	}
+	signers, err := client.Signers()
+	if err != nil {
+		return skip(fmt.Errorf("cannot list ssh-agent keys: %s", err))
+	}
+
+	labelled := make([]ssh.Signer, 0, len(signers))
+	for i, signer := range signers {
+		label := "publickey (agent)"
+		if i < len(keys) && keys[i].Comment != "" {
+			label = "publickey (agent " + keys[i].Comment + ")"
+		}
+		labelled = append(labelled, auth.label(signer, label))
+	}
+	return labelled, nil
+}
+
+func (opts *sshOpts) makeAuth(identityFile string) (*sshAuth, error) {
+	auth := &sshAuth{}
+
+	identity, err := opts.identitySigners(auth, identityFile)
+	if err != nil {
+		return nil, err
+	}
+
+	agentKeys, err := opts.agentSigners(auth)
+	if err != nil {
+		auth.Close()
+		return nil, err
+	}
+
+	// Every public key has to go through a single method, the client never
+	// retries a method name it has already tried.
+	signers := append(identity, agentKeys...)
+	if len(signers) > 0 {
+		auth.methods = append(auth.methods, ssh.PublicKeys(signers...))
+	}
+	if opts.Password != "" {
+		auth.methods = append(auth.methods, ssh.PasswordCallback(func() (string, error) {
+			auth.used = "password"
+			return opts.Password, nil
+		}))
+	}
+	return auth, nil
+}
@@ -5,28 +5,75 @@ import (
 	"encoding/pem"
 	"errors"
 	"fmt"
-	"io/ioutil"
+	"math"
 	"net"
 	"os"
 	"strconv"
-	"strings"
 	"time"
 
 	"github.com/jessevdk/go-flags"
+	"github.com/kevinburke/ssh_config"
 	"github.com/mackerelio/checkers"
 	"golang.org/x/crypto/ssh"
 )
 
 type sshOpts struct {
-	Hostname     string  `short:"H" long:"hostname" default:"localhost" description:"Host name or IP Address"`
-	Port         int     `short:"P" long:"port" default:"22" description:"Port number"`
-	Timeout      float64 `short:"t" long:"timeout" default:"30" description:"Seconds before connection times out"`
-	Warning      int64   `short:"w" long:"warning" default:"5" description:"Response time to result in warning status (seconds)"`
-	Critical     int64   `short:"c" long:"critical" default:"10" description:"Response time to result in critical status (seconds)"`
-	User         string  `short:"u" long:"user" description:"Login user name" env:"USER"`
-	Password     string  `short:"p" long:"password" description:"Login password" env:"LOGIN_PASSWORD"`
-	IdentityFile string  `short:"i" long:"identity" description:"Identity file (ssh private key)"`
-	PassPhrase   string  `long:"passphrase" description:"Identity passphrase" env:"CHECK_SSH_IDENTITY_PASSPHRASE"`
+	Hostname         []string `short:"H" long:"hostname" description:"Host name or IP Address, can be repeated (default: localhost)"`
+	HostsFile        string   `long:"hosts-file" description:"File listing one host per line"`
+	Concurrency      int      `long:"concurrency" default:"10" description:"Maximum number of hosts checked at the same time"`
+	Port             int      `short:"P" long:"port" description:"Port number (default: 22 in ssh mode, 123 in ntp mode, 443 in http and tls modes)"`
+	Mode             string   `long:"mode" default:"ssh" choice:"ssh" choice:"ntp" choice:"http" choice:"tls" choice:"local" description:"Time source to compare with, local checks the local clock synchronisation instead"`
+	Reference        string   `long:"reference" description:"Compare with this host instead of the local clock"`
+	ReferenceMode    string   `long:"reference-mode" choice:"ssh" choice:"ntp" choice:"http" choice:"tls" description:"How to query the reference host (default: --mode)"`
+	Timeout          float64  `short:"t" long:"timeout" default:"30" description:"Seconds allowed for the whole check of a host"`
+	Warning          float64  `short:"w" long:"warning" default:"5" description:"Time difference to result in warning status (seconds, fractions allowed)"`
+	Critical         float64  `short:"c" long:"critical" default:"10" description:"Time difference to result in critical status (seconds, fractions allowed)"`
+	WarningAhead     *float64 `long:"warning-ahead" description:"Warning threshold when the remote clock is ahead (seconds, default: --warning)"`
+	CriticalAhead    *float64 `long:"critical-ahead" description:"Critical threshold when the remote clock is ahead (seconds, default: --critical)"`
+	WarningBehind    *float64 `long:"warning-behind" description:"Warning threshold when the remote clock is behind (seconds, default: --warning)"`
+	CriticalBehind   *float64 `long:"critical-behind" description:"Critical threshold when the remote clock is behind (seconds, default: --critical)"`
+	Samples          int      `short:"n" long:"samples" default:"1" description:"Number of samples to average (ssh, http and tls modes)"`
+	StateFile        string   `long:"state-file" description:"Record the offsets in this file to compute the drift rate across runs"`
+	DriftWindow      float64  `long:"drift-window" default:"24" description:"Hours of recorded offsets used to compute the drift rate"`
+	WarningDrift     *float64 `long:"warning-drift" description:"Drift rate to result in warning status (ppm, requires --state-file)"`
+	CriticalDrift    *float64 `long:"critical-drift" description:"Drift rate to result in critical status (ppm, requires --state-file)"`
+	SyncStatus       bool     `long:"sync-status" description:"Also report the remote NTP synchronisation state, WARNING when not synchronized (ssh mode)"`
+	RemoteCommand    string   `long:"remote-command" description:"Command printing the remote time (ssh mode, default: date +%s.%N, date +%s with epoch, date --rfc-3339=ns with rfc3339)"`
+	RemoteFormat     string   `long:"remote-format" default:"epoch-fraction" choice:"epoch" choice:"epoch-fraction" choice:"rfc3339" choice:"layout" description:"Format of the remote command output"`
+	RemoteLayout     string   `long:"remote-layout" description:"Go time layout of the remote command output, with --remote-format layout"`
+	RemoteTimezone   string   `long:"remote-timezone" default:"UTC" description:"Time zone of the remote command output when the layout has none"`
+	HTTPPath         string   `long:"http-path" default:"/" description:"Path requested in http mode"`
+	PlainHTTP        bool     `long:"plain-http" description:"Use http:// instead of https:// in http mode (default port: 80)"`
+	Insecure         bool     `long:"insecure" description:"Do not verify the server certificate in http mode"`
+	RTC              bool     `long:"rtc" description:"Also compare the remote hardware clock (RTC) with the remote system clock (ssh mode)"`
+	WarningRTC       float64  `long:"warning-rtc" default:"5" description:"RTC skew to result in warning status (seconds)"`
+	CriticalRTC      float64  `long:"critical-rtc" default:"30" description:"RTC skew to result in critical status (seconds)"`
+	WarningEstError  float64  `long:"warning-esterror" default:"0.1" description:"Estimated error of the local clock to result in warning status (seconds, local mode)"`
+	CriticalEstError float64  `long:"critical-esterror" default:"1" description:"Estimated error of the local clock to result in critical status (seconds, local mode)"`
+	WarningMaxError  float64  `long:"warning-maxerror" default:"1" description:"Maximum error of the local clock to result in warning status (seconds, local mode)"`
+	CriticalMaxError float64  `long:"critical-maxerror" default:"10" description:"Maximum error of the local clock to result in critical status (seconds, local mode)"`
+	User             string   `short:"u" long:"user" description:"Login user name" env:"USER"`
+	Password         string   `short:"p" long:"password" description:"Login password" env:"LOGIN_PASSWORD"`
+	IdentityFile     string   `short:"i" long:"identity" description:"Identity file (ssh private key)"`
+	PassPhrase       string   `long:"passphrase" description:"Identity passphrase" env:"CHECK_SSH_IDENTITY_PASSPHRASE"`
+	Certificate      string   `long:"certificate" description:"OpenSSH user certificate (default: <identity>-cert.pub when present)"`
+	AgentSocket      string   `long:"agent-socket" description:"ssh-agent socket" env:"SSH_AUTH_SOCK"`
+	NoAgent          bool     `long:"no-agent" description:"Do not use ssh-agent keys"`
+
+	KnownHosts         string `long:"known-hosts" description:"Known hosts file (default: ~/.ssh/known_hosts)"`
+	HostKeyFingerprint string `long:"host-key-fingerprint" description:"Expected host key fingerprint (SHA256:... or MD5:...), bypasses known hosts"`
+	HostKeyPolicy      string `long:"host-key-policy" default:"strict" choice:"strict" choice:"accept-new" choice:"off" description:"Host key verification policy"`
+
+	Jump      []string `short:"J" long:"jump" description:"Jump host [user@]host[:port], comma separated or repeated for a chain (like ssh -J)"`
+	SSHConfig string   `short:"F" long:"ssh-config" description:"Resolve host aliases (HostName, Port, User, IdentityFile, ProxyJump) through this ssh_config file"`
+
+	host           string
+	userSet        bool
+	agentSocketSet bool
+	sshConfig      *ssh_config.Config
+	reference      *referenceClock
+	parseDate      remoteDateParser
+	drift          *driftState
 }
 
 // Do the plugin
@@ -43,134 +90,446 @@ func Do() {
 
 func parseArgs(args []string) (*sshOpts, error) {
 	opts := &sshOpts{}
-	_, err := flags.ParseArgs(opts, args)
-	return opts, err
-}
-
-func decrypt(block *pem.Block, passphrase string) (*pem.Block, error) {
-	data, err := x509.DecryptPEMBlock(block, []byte(passphrase))
+	parser := flags.NewParser(opts, flags.Default)
+	_, err := parser.ParseArgs(args)
 	if err != nil {
-		return nil, err
+		return opts, err
 	}
 
-	decryptedBlock := &pem.Block{
-		Type:  block.Type,
-		Bytes: data,
-	}
-	return decryptedBlock, nil
+	// an explicit -u overrides ssh_config, the $USER fallback does not
+	user := parser.FindOptionByLongName("user")
+	opts.userSet = user.IsSet() && !user.IsSetDefault()
+	// likewise only an explicit --agent-socket has to be reachable
+	agentSocket := parser.FindOptionByLongName("agent-socket")
+	opts.agentSocketSet = agentSocket.IsSet() && !agentSocket.IsSetDefault()
+	return opts, nil
 }
 
-func readPrivateKey(file, passphrase string) ([]byte, error) {
-	privateKey, err := ioutil.ReadFile(file)
+// readPrivateKey loads an identity in any format produced by ssh-keygen or
+// openssl: OpenSSH ("OPENSSH PRIVATE KEY"), PKCS#8 and legacy PEM (PKCS#1,
+// SEC1), optionally protected by a passphrase.
+func readPrivateKey(file, passphrase string) (ssh.Signer, error) {
+	privateKey, err := os.ReadFile(file)
 	if err != nil {
 		return nil, err
 	}
 
-	block, rest := pem.Decode(privateKey)
-	if len(rest) > 0 {
-		return nil, errors.New("Invalid private key")
+	block, _ := pem.Decode(privateKey)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM encoded private key found", file)
 	}
-	if !x509.IsEncryptedPEMBlock(block) {
-		return privateKey, nil
+	if block.Type == "ENCRYPTED PRIVATE KEY" {
+		return readEncryptedPKCS8(file, block.Bytes, passphrase)
 	}
 
-	block, err = decrypt(block, passphrase)
+	signer, err := ssh.ParsePrivateKey(privateKey)
+	var missing *ssh.PassphraseMissingError
+	if !errors.As(err, &missing) {
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", file, err)
+		}
+		return signer, nil
+	}
+	if passphrase == "" {
+		return nil, fmt.Errorf("%s: private key is encrypted but no passphrase was given", file)
+	}
+
+	signer, err = ssh.ParsePrivateKeyWithPassphrase(privateKey, []byte(passphrase))
+	if errors.Is(err, x509.IncorrectPasswordError) {
+		return nil, fmt.Errorf("%s: incorrect passphrase", file)
+	}
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("%s: %s", file, err)
 	}
+	return signer, nil
+}
 
-	privateKey = pem.EncodeToMemory(block)
-	return privateKey, nil
+// readEncryptedPKCS8 decrypts a key that ssh.ParsePrivateKey cannot.
+func readEncryptedPKCS8(file string, der []byte, passphrase string) (ssh.Signer, error) {
+	if passphrase == "" {
+		return nil, fmt.Errorf("%s: private key is encrypted but no passphrase was given", file)
+	}
+	key, err := decryptPKCS8(der, passphrase)
+	if errors.Is(err, x509.IncorrectPasswordError) {
+		return nil, fmt.Errorf("%s: incorrect passphrase", file)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", file, err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", file, err)
+	}
+	return signer, nil
 }
 
-func (opts *sshOpts) makeClientConfig() (*ssh.ClientConfig, error) {
-	authenticities := make([]ssh.AuthMethod, 0, 1)
-	if opts.Password != "" {
-		authenticities = append(authenticities, ssh.Password(opts.Password))
+func (opts *sshOpts) port() int {
+	if opts.Port != 0 {
+		return opts.Port
 	}
-	if opts.IdentityFile != "" {
-		data, err := readPrivateKey(opts.IdentityFile, opts.PassPhrase)
-		if err != nil {
-			return nil, err
+	switch opts.Mode {
+	case "ntp":
+		return 123
+	case "http":
+		if opts.PlainHTTP {
+			return 80
 		}
+		return 443
+	case "tls":
+		return 443
+	}
+	return 22
+}
 
-		signer, err := ssh.ParsePrivateKey(data)
-		if err != nil {
-			return nil, err
-		}
+func (opts *sshOpts) addr() string {
+	return net.JoinHostPort(opts.host, strconv.Itoa(opts.port()))
+}
+
+func (opts *sshOpts) makeClientConfig(e endpoint, fingerprint string) (*ssh.ClientConfig, *sshAuth, error) {
+	auth, err := opts.makeAuth(e.identity)
+	if err != nil {
+		return nil, nil, err
+	}
 
-		authenticities = append(authenticities, ssh.PublicKeys(signer))
+	hostKeyCallback, hostKeyAlgorithms, err := opts.hostKeyConfig(e.addr(), fingerprint)
+	if err != nil {
+		auth.Close()
+		return nil, nil, err
 	}
 
-	config := &ssh.ClientConfig{User: opts.User, Auth: authenticities, HostKeyCallback: ssh.InsecureIgnoreHostKey()}
-	return config, nil
+	config := &ssh.ClientConfig{User: e.user, Auth: auth.methods, HostKeyCallback: hostKeyCallback, HostKeyAlgorithms: hostKeyAlgorithms}
+	return config, auth, nil
 }
 
-func (opts *sshOpts) dial(config *ssh.ClientConfig) (*ssh.Client, error) {
-	addr := opts.Hostname + ":" + strconv.Itoa(opts.Port)
-	timeout := opts.Timeout * float64(time.Second)
-	conn, err := net.DialTimeout("tcp", addr, time.Duration(timeout))
+// dialEndpoint connects to e, directly or tunnelled through the via client.
+func (opts *sshOpts) dialEndpoint(d *deadline, via *ssh.Client, e endpoint, fingerprint string) (*ssh.Client, *sshAuth, error) {
+	config, auth, err := opts.makeClientConfig(e, fingerprint)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
+
+	hostKeyCallback := config.HostKeyCallback
+	config.HostKeyCallback = func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		if err := hostKeyCallback(hostname, remote, key); err != nil {
+			return err
+		}
+		d.enter(phaseAuthentication)
+		return nil
+	}
+
+	d.enter(phaseConnect)
+	addr := e.addr()
+	var conn net.Conn
+	if via == nil {
+		var dialer net.Dialer
+		conn, err = dialer.DialContext(d.ctx, "tcp", addr)
+	} else {
+		conn, err = via.Dial("tcp", addr)
+	}
+	if err != nil {
+		return nil, auth, d.wrap(unreachable(err))
+	}
+	d.watch(conn)
+
+	d.enter(phaseHandshake)
 	c, chans, reqs, err := ssh.NewClientConn(conn, addr, config)
 	if err != nil {
-		return nil, err
+		conn.Close()
+		if auth.agentSkipped != nil {
+			err = fmt.Errorf("%w (ssh-agent skipped: %s)", err, auth.agentSkipped)
+		}
+		// the host answered: a host key mismatch or a failed authentication
+		// is not a network problem
+		return nil, auth, d.wrap(critical(err))
 	}
-	return ssh.NewClient(c, chans, reqs), nil
+	return ssh.NewClient(c, chans, reqs), auth, nil
 }
 
 func (opts *sshOpts) run() *checkers.Checker {
+	if opts.Samples < 1 {
+		return checkers.Unknown("--samples must be at least 1")
+	}
+	if err := opts.validateThresholds(); err != nil {
+		return checkers.Unknown(err.Error())
+	}
+	parseDate, err := opts.dateParser()
+	if err != nil {
+		return checkers.Unknown(err.Error())
+	}
+	opts.parseDate = parseDate
+	if err := opts.defaultRemoteCommand(); err != nil {
+		return checkers.Unknown(err.Error())
+	}
+
+	// the local clock does not depend on any host
+	if opts.Mode == "local" {
+		return opts.target("localhost").check()
+	}
+
 	// prevent changing output of some commands
 	os.Setenv("LANG", "C")
 	os.Setenv("LC_ALL", "C")
 
-	config, err := opts.makeClientConfig()
+	hosts, err := opts.hosts()
 	if err != nil {
 		return checkers.Unknown(err.Error())
 	}
 
-	client, err := opts.dial(config)
+	if opts.SSHConfig != "" {
+		opts.sshConfig, err = loadSSHConfig(opts.SSHConfig)
+		if err != nil {
+			return checkers.Unknown(err.Error())
+		}
+	}
+	if opts.StateFile != "" {
+		opts.drift, err = loadDriftState(opts.StateFile, time.Duration(opts.DriftWindow*float64(time.Hour)))
+		if err != nil {
+			return checkers.Unknown(fmt.Sprintf("cannot read state file: %s", err))
+		}
+	}
+	if opts.Reference != "" {
+		opts.reference = opts.startReference()
+	}
+
+	var ckr *checkers.Checker
+	if len(hosts) == 1 {
+		ckr = opts.target(hosts[0]).check()
+	} else {
+		ckr = opts.checkHosts(hosts)
+	}
+
+	if opts.drift != nil {
+		if err := opts.drift.save(time.Now()); err != nil {
+			ckr.Status = worse(ckr.Status, checkers.UNKNOWN)
+			ckr.Message += fmt.Sprintf(" - cannot save state file: %s", err)
+		}
+	}
+	return ckr
+}
+
+// target returns a copy of the options bound to a single host, given as
+// host[:port]. A port given with the host wins over --port.
+func (opts *sshOpts) target(spec string) *sshOpts {
+	t := *opts
+	host, port := splitHostPort(spec)
+	t.host = host
+	if port != 0 {
+		t.Port = port
+	}
+	return &t
+}
+
+func (opts *sshOpts) measure() (*measurement, error) {
+	d, cancel := opts.newDeadline()
+	defer cancel()
+
+	var m *measurement
+	var err error
+	switch opts.Mode {
+	case "ntp":
+		m, err = opts.measureNTP(d)
+	case "http":
+		m, err = opts.measureHTTP(d)
+	case "tls":
+		m, err = opts.measureTLS(d)
+	case "local":
+		m, err = opts.measureLocal(d)
+	default:
+		m, err = opts.measureSSH(d)
+	}
 	if err != nil {
-		return checkers.Critical(err.Error())
+		return nil, err
 	}
 
-	session, err := client.NewSession()
+	if opts.drift != nil {
+		opts.trackDrift(m)
+	}
+	return m, nil
+}
+
+func (opts *sshOpts) check() *checkers.Checker {
+	m, err := opts.measure()
 	if err != nil {
-		return checkers.Critical(err.Error())
+		return checkers.NewChecker(statusOf(err), err.Error())
 	}
 
-	output, err := session.Output("date +%s")
+	return checkers.NewChecker(opts.evaluate(m), m.String())
+}
+
+func (opts *sshOpts) evaluate(m *measurement) checkers.Status {
+	return worse(m.status, opts.checkState(m.offset()))
+}
+
+func (opts *sshOpts) measureSSH(d *deadline) (*measurement, error) {
+	client, err := opts.connect(d)
 	if err != nil {
-		return checkers.Unknown(err.Error())
+		return nil, err
+	}
+	defer client.Close()
+
+	samples := make([]sample, 0, opts.Samples)
+	for i := 0; i < opts.Samples; i++ {
+		s, err := opts.sampleSSH(d, client.Client)
+		if err != nil {
+			return nil, err
+		}
+		samples = append(samples, s)
+	}
+	result := averageSamples(samples)
+	if err := opts.rebase(&result); err != nil {
+		return nil, err
+	}
+
+	m := &measurement{sample: result}
+	m.add("%s: %s", opts.baselineLabel(), formatDate(result.local))
+	m.add("Remote date: %s", formatDate(result.remote))
+	m.add("Diff time: %s", describeOffset(result.offset()))
+	m.add("Round trip: %s", result.delay)
+	m.add("Auth: %s", client.auth.Used())
+	if client.auth.agentSkipped != nil {
+		m.add("Agent: skipped (%s)", client.auth.agentSkipped)
+	}
+
+	if opts.SyncStatus {
+		status, err := querySyncStatus(d, client.Client)
+		if err != nil {
+			m.add("Synchronized: unknown (%s)", err)
+			m.raise(checkers.UNKNOWN, "sync status unknown")
+		} else {
+			status.addTo(m)
+		}
+	}
+	if opts.RTC {
+		rtc, err := queryRTC(d, client.Client)
+		if err != nil {
+			m.add("RTC skew: unknown (%s)", err)
+			m.raise(checkers.UNKNOWN, "RTC unknown")
+		} else {
+			rtc.addTo(opts, m)
+		}
 	}
+	return m, nil
+}
 
-	outputStr := string(output)
-	outputClean := strings.TrimRight(outputStr, "\r\n")
+func formatDate(t time.Time) string {
+	return fmt.Sprintf("%.3f (%s)", float64(t.UnixNano())/float64(time.Second), t.Round(time.Millisecond))
+}
 
-	remoteDate, err := strconv.ParseInt(outputClean, 10, 64)
-	startDate := time.Now().Unix()
+// thresholds returns the warning and critical thresholds, in seconds, that
+// apply to an offset in its direction. The direction specific options
+// fall back to -w and -c.
+func (opts *sshOpts) thresholds(offset time.Duration) (float64, float64) {
+	warning, critical := opts.WarningAhead, opts.CriticalAhead
+	if offset < 0 {
+		warning, critical = opts.WarningBehind, opts.CriticalBehind
+	}
+	if warning == nil {
+		warning = &opts.Warning
+	}
+	if critical == nil {
+		critical = &opts.Critical
+	}
+	return *warning, *critical
+}
 
-	diffTime := Abs(startDate - remoteDate)
+// checkState maps a signed offset (remote minus local) to a status. A zero
+// offset is always OK, otherwise the offset is compared, inclusively, with
+// the thresholds of its direction.
+func (opts *sshOpts) checkState(offset time.Duration) checkers.Status {
+	if offset == 0 {
+		return checkers.OK
+	}
 
-	var checkState checkers.Status
+	warning, critical := opts.thresholds(offset)
+	diffTime := math.Abs(offset.Seconds())
+	if diffTime >= critical {
+		return checkers.CRITICAL
+	}
+	if diffTime >= warning {
+		return checkers.WARNING
+	}
+	return checkers.OK
+}
+
+func (opts *sshOpts) validateThresholds() error {
+	for _, threshold := range []*float64{&opts.Warning, &opts.Critical, opts.WarningAhead, opts.CriticalAhead, opts.WarningBehind, opts.CriticalBehind, opts.WarningDrift, opts.CriticalDrift, &opts.WarningRTC, &opts.CriticalRTC, &opts.WarningEstError, &opts.CriticalEstError, &opts.WarningMaxError, &opts.CriticalMaxError} {
+		if threshold != nil && *threshold < 0 {
+			return errors.New("thresholds must not be negative")
+		}
+	}
+	if (opts.WarningDrift != nil || opts.CriticalDrift != nil) && opts.StateFile == "" {
+		return errors.New("drift thresholds require --state-file")
+	}
+	if opts.DriftWindow <= 0 {
+		return errors.New("--drift-window must be positive")
+	}
+	return nil
+}
 
-	if diffTime > 0 && diffTime < opts.Warning {
-		checkState = checkers.OK
-	} else if diffTime >= opts.Warning && diffTime < opts.Critical {
-		checkState = checkers.WARNING
-	} else if diffTime >= opts.Critical {
-		checkState = checkers.CRITICAL
+func describeOffset(offset time.Duration) string {
+	switch {
+	case offset > 0:
+		return fmt.Sprintf("remote ahead by %.3fs", offset.Seconds())
+	case offset < 0:
+		return fmt.Sprintf("remote behind by %.3fs", -offset.Seconds())
+	default:
+		return "remote in sync"
 	}
+}
 
-	message := fmt.Sprintf("Current date: %d (%s) - Remote date: %d (%s) - Diff time: %d", startDate, time.Unix(startDate, 0), remoteDate, time.Unix(remoteDate, 0), diffTime)
-	return checkers.NewChecker(checkState, message)
+// statusError carries the status a failed measurement should be reported with.
+type statusError struct {
+	status      checkers.Status
+	unreachable bool
+	err         error
+}
+
+func (e *statusError) Error() string {
+	return e.err.Error()
+}
+
+func (e *statusError) Unwrap() error {
+	return e.err
+}
+
+func critical(err error) error {
+	return &statusError{status: checkers.CRITICAL, err: err}
+}
+
+func unreachable(err error) error {
+	return &statusError{status: checkers.CRITICAL, unreachable: true, err: err}
+}
+
+func statusOf(err error) checkers.Status {
+	var statusErr *statusError
+	if errors.As(err, &statusErr) {
+		return statusErr.status
+	}
+	return checkers.UNKNOWN
+}
+
+func isUnreachable(err error) bool {
+	var statusErr *statusError
+	return errors.As(err, &statusErr) && statusErr.unreachable
+}
+
+// severity orders statuses from best to worst, CRITICAL being worse than UNKNOWN.
+func severity(status checkers.Status) int {
+	switch status {
+	case checkers.OK:
+		return 0
+	case checkers.WARNING:
+		return 1
+	case checkers.UNKNOWN:
+		return 2
+	default:
+		return 3
+	}
 }
 
-// Abs returns the absolute value of x.
-func Abs(x int64) int64 {
-	if x < 0 {
-		return -x
+func worse(a, b checkers.Status) checkers.Status {
+	if severity(b) > severity(a) {
+		return b
 	}
-	return x
+	return a
 }
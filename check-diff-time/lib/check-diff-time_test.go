@@ -0,0 +1,126 @@
+package checkdifftime
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mackerelio/checkers"
+)
+
+func TestReadPrivateKey(t *testing.T) {
+	tests := []struct {
+		file       string
+		passphrase string
+		keyType    string
+		err        string
+	}{
+		{file: "openssh_ed25519", keyType: "ssh-ed25519"},
+		{file: "openssh_ed25519_encrypted", passphrase: "secret", keyType: "ssh-ed25519"},
+		{file: "openssh_ecdsa", keyType: "ecdsa-sha2-nistp256"},
+		{file: "openssh_ecdsa_encrypted", passphrase: "secret", keyType: "ecdsa-sha2-nistp256"},
+		{file: "pkcs1_rsa.pem", keyType: "ssh-rsa"},
+		{file: "pkcs1_rsa_encrypted.pem", passphrase: "secret", keyType: "ssh-rsa"},
+		{file: "sec1_ecdsa.pem", keyType: "ecdsa-sha2-nistp256"},
+		{file: "sec1_ecdsa_encrypted.pem", passphrase: "secret", keyType: "ecdsa-sha2-nistp256"},
+		{file: "pkcs8_rsa.pem", keyType: "ssh-rsa"},
+		{file: "pkcs8_ed25519.pem", keyType: "ssh-ed25519"},
+		{file: "pkcs8_rsa_encrypted.pem", passphrase: "secret", keyType: "ssh-rsa"},
+		{file: "pkcs8_ed25519_encrypted.pem", passphrase: "secret", keyType: "ssh-ed25519"},
+		{file: "pkcs8_ecdsa_3des_encrypted.pem", passphrase: "secret", keyType: "ecdsa-sha2-nistp256"},
+
+		// a passphrase is ignored by plain keys
+		{file: "openssh_ed25519", passphrase: "secret", keyType: "ssh-ed25519"},
+
+		{file: "openssh_ed25519_encrypted", passphrase: "wrong", err: "incorrect passphrase"},
+		{file: "pkcs1_rsa_encrypted.pem", passphrase: "wrong", err: "incorrect passphrase"},
+		{file: "sec1_ecdsa_encrypted.pem", passphrase: "wrong", err: "incorrect passphrase"},
+		{file: "pkcs8_rsa_encrypted.pem", passphrase: "wrong", err: "incorrect passphrase"},
+		{file: "pkcs8_ed25519_encrypted.pem", passphrase: "wrong", err: "incorrect passphrase"},
+
+		{file: "openssh_ecdsa_encrypted", err: "no passphrase was given"},
+		{file: "pkcs1_rsa_encrypted.pem", err: "no passphrase was given"},
+		{file: "sec1_ecdsa_encrypted.pem", err: "no passphrase was given"},
+		{file: "pkcs8_rsa_encrypted.pem", err: "no passphrase was given"},
+
+		{file: "missing", err: "no such file"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file+"/"+tt.passphrase, func(t *testing.T) {
+			signer, err := readPrivateKey(filepath.Join("testdata", tt.file), tt.passphrase)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("got error %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := signer.PublicKey().Type(); got != tt.keyType {
+				t.Errorf("got key type %s, want %s", got, tt.keyType)
+			}
+		})
+	}
+}
+
+func TestCheckState(t *testing.T) {
+	float := func(v float64) *float64 { return &v }
+	symmetric := &sshOpts{Warning: 5, Critical: 10}
+	directional := &sshOpts{Warning: 5, Critical: 10, WarningAhead: float(1), CriticalAhead: float(2), WarningBehind: float(20), CriticalBehind: float(30)}
+	// only one side of each direction overridden
+	partial := &sshOpts{Warning: 5, Critical: 10, WarningAhead: float(1), CriticalBehind: float(30)}
+	zero := &sshOpts{Warning: 0, Critical: 0}
+	justBelow := func(seconds float64) time.Duration {
+		return time.Duration(seconds*float64(time.Second)) - time.Millisecond
+	}
+
+	tests := []struct {
+		name   string
+		opts   *sshOpts
+		offset time.Duration
+		want   checkers.Status
+	}{
+		{"zero", symmetric, 0, checkers.OK},
+		{"ahead below warning", symmetric, justBelow(5), checkers.OK},
+		{"ahead at warning", symmetric, 5 * time.Second, checkers.WARNING},
+		{"ahead below critical", symmetric, justBelow(10), checkers.WARNING},
+		{"ahead at critical", symmetric, 10 * time.Second, checkers.CRITICAL},
+		{"behind below warning", symmetric, -justBelow(5), checkers.OK},
+		{"behind at warning", symmetric, -5 * time.Second, checkers.WARNING},
+		{"behind below critical", symmetric, -justBelow(10), checkers.WARNING},
+		{"behind at critical", symmetric, -10 * time.Second, checkers.CRITICAL},
+
+		{"directional zero", directional, 0, checkers.OK},
+		{"directional ahead below warning", directional, justBelow(1), checkers.OK},
+		{"directional ahead at warning", directional, time.Second, checkers.WARNING},
+		{"directional ahead below critical", directional, justBelow(2), checkers.WARNING},
+		{"directional ahead at critical", directional, 2 * time.Second, checkers.CRITICAL},
+		{"directional behind below warning", directional, -justBelow(20), checkers.OK},
+		{"directional behind at warning", directional, -20 * time.Second, checkers.WARNING},
+		{"directional behind below critical", directional, -justBelow(30), checkers.WARNING},
+		{"directional behind at critical", directional, -30 * time.Second, checkers.CRITICAL},
+
+		{"ahead warning override", partial, time.Second, checkers.WARNING},
+		{"ahead critical falls back to -c", partial, justBelow(10), checkers.WARNING},
+		{"ahead at -c", partial, 10 * time.Second, checkers.CRITICAL},
+		{"behind warning falls back to -w", partial, -5 * time.Second, checkers.WARNING},
+		{"behind below -w", partial, -justBelow(5), checkers.OK},
+		{"behind critical override", partial, -justBelow(30), checkers.WARNING},
+		{"behind at critical override", partial, -30 * time.Second, checkers.CRITICAL},
+
+		{"zero thresholds with zero offset", zero, 0, checkers.OK},
+		{"zero thresholds ahead", zero, time.Nanosecond, checkers.CRITICAL},
+		{"zero thresholds behind", zero, -time.Nanosecond, checkers.CRITICAL},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.opts.checkState(tt.offset); got != tt.want {
+				t.Errorf("checkState(%s) = %s, want %s", tt.offset, got, tt.want)
+			}
+		})
+	}
+}
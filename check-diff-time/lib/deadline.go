@@ -0,0 +1,80 @@
+package checkdifftime
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/mackerelio/checkers"
+)
+
+const (
+	phaseConnect        = "connect"
+	phaseHandshake      = "handshake"
+	phaseAuthentication = "authentication"
+	phaseSession        = "session"
+	phaseCommand        = "command"
+	phaseNTPQuery       = "ntp query"
+	phaseRequest        = "request"
+)
+
+// deadline bounds the whole check of one host, from the TCP connection to
+// the last remote command. The SSH library does not take a context, so the
+// connections are closed when it expires, which unblocks whatever is
+// waiting on them.
+type deadline struct {
+	ctx     context.Context
+	timeout time.Duration
+	host    string
+
+	mu    sync.Mutex
+	phase string
+}
+
+func (opts *sshOpts) newDeadline() (*deadline, context.CancelFunc) {
+	timeout := time.Duration(opts.Timeout * float64(time.Second))
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	return &deadline{ctx: ctx, timeout: timeout, host: opts.host, phase: phaseConnect}, cancel
+}
+
+func (d *deadline) enter(phase string) {
+	d.mu.Lock()
+	d.phase = phase
+	d.mu.Unlock()
+}
+
+func (d *deadline) currentPhase() string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.phase
+}
+
+// watch closes c as soon as the deadline expires or the check is over.
+func (d *deadline) watch(c io.Closer) {
+	go func() {
+		<-d.ctx.Done()
+		c.Close()
+	}()
+}
+
+// wrap replaces err by a timeout error naming the current phase when the
+// deadline caused it.
+func (d *deadline) wrap(err error) error {
+	if err == nil {
+		return nil
+	}
+	if d.ctx.Err() == nil && !errors.Is(err, os.ErrDeadlineExceeded) && !errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+
+	phase := d.currentPhase()
+	return &statusError{
+		status:      checkers.UNKNOWN,
+		unreachable: phase == phaseConnect,
+		err:         fmt.Errorf("timeout after %s during %s with %s", d.timeout, phase, d.host),
+	}
+}
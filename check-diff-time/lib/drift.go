@@ -0,0 +1,164 @@
+package checkdifftime
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/mackerelio/checkers"
+)
+
+// driftRecord is one offset, in seconds, measured at Time (epoch seconds).
+type driftRecord struct {
+	Time   float64 `json:"time"`
+	Offset float64 `json:"offset"`
+}
+
+// driftState is the history of offsets per host kept in --state-file. It
+// is loaded once per run, updated by every host check then saved.
+type driftState struct {
+	file   string
+	window time.Duration
+
+	mu    sync.Mutex
+	Hosts map[string][]driftRecord `json:"hosts"`
+}
+
+func loadDriftState(file string, window time.Duration) (*driftState, error) {
+	state := &driftState{file: file, window: window, Hosts: map[string][]driftRecord{}}
+
+	data, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("%s: %s", file, err)
+	}
+	if state.Hosts == nil {
+		state.Hosts = map[string][]driftRecord{}
+	}
+	return state, nil
+}
+
+// save replaces the state file atomically so that a concurrent run never
+// reads a partial file.
+func (state *driftState) save(now time.Time) error {
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	// hosts no longer checked age out with the window
+	for host, records := range state.Hosts {
+		if records = state.prune(records, now); len(records) == 0 {
+			delete(state.Hosts, host)
+		} else {
+			state.Hosts[host] = records
+		}
+	}
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(state.file), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(state.file), filepath.Base(state.file)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), state.file)
+}
+
+func (state *driftState) prune(records []driftRecord, now time.Time) []driftRecord {
+	oldest := epochSeconds(now.Add(-state.window))
+	kept := records[:0]
+	for _, r := range records {
+		if r.Time >= oldest {
+			kept = append(kept, r)
+		}
+	}
+	return kept
+}
+
+// record adds the offset measured for host and returns the records of the
+// window.
+func (state *driftState) record(host string, at time.Time, offset time.Duration) []driftRecord {
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	records := append(state.Hosts[host], driftRecord{Time: epochSeconds(at), Offset: offset.Seconds()})
+	records = state.prune(records, at)
+	state.Hosts[host] = records
+	return append([]driftRecord(nil), records...)
+}
+
+func epochSeconds(t time.Time) float64 {
+	return float64(t.UnixNano()) / float64(time.Second)
+}
+
+// driftRate is the slope of the least squares line through the offsets, in
+// parts per million: a positive rate means the remote clock runs faster.
+func driftRate(records []driftRecord) float64 {
+	var meanTime, meanOffset float64
+	for _, r := range records {
+		meanTime += r.Time
+		meanOffset += r.Offset
+	}
+	meanTime /= float64(len(records))
+	meanOffset /= float64(len(records))
+
+	var covariance, variance float64
+	for _, r := range records {
+		covariance += (r.Time - meanTime) * (r.Offset - meanOffset)
+		variance += (r.Time - meanTime) * (r.Time - meanTime)
+	}
+	if variance == 0 {
+		return 0
+	}
+	return covariance / variance * 1e6
+}
+
+// trackDrift records the offset of m and reports the drift rate over the
+// window once the history covers at least a quarter of it.
+func (opts *sshOpts) trackDrift(m *measurement) {
+	records := opts.drift.record(opts.host, m.local, m.offset())
+
+	span := time.Duration((records[len(records)-1].Time - records[0].Time) * float64(time.Second))
+	if len(records) < 2 || span < opts.drift.window/4 {
+		m.add("Drift: unknown (%d samples over %s)", len(records), span.Round(time.Second))
+		return
+	}
+
+	ppm := driftRate(records)
+	m.add("Drift: %+.3f ppm (%+.3fs/day over %s, %d samples)", ppm, ppm*86400/1e6, span.Round(time.Second), len(records))
+	if status := opts.checkDrift(ppm); status != checkers.OK {
+		m.raise(status, fmt.Sprintf("drift %+.3f ppm", ppm))
+	}
+}
+
+func (opts *sshOpts) checkDrift(ppm float64) checkers.Status {
+	rate := math.Abs(ppm)
+	if opts.CriticalDrift != nil && rate >= *opts.CriticalDrift {
+		return checkers.CRITICAL
+	}
+	if opts.WarningDrift != nil && rate >= *opts.WarningDrift {
+		return checkers.WARNING
+	}
+	return checkers.OK
+}
@@ -0,0 +1,154 @@
+package checkdifftime
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+const (
+	hostKeyPolicyStrict    = "strict"
+	hostKeyPolicyAcceptNew = "accept-new"
+	hostKeyPolicyOff       = "off"
+)
+
+type hostKeyError struct {
+	host    string
+	key     ssh.PublicKey
+	problem string
+}
+
+func (e *hostKeyError) Error() string {
+	return fmt.Sprintf("%s for %s: server offered %s %s", e.problem, e.host, e.key.Type(), ssh.FingerprintSHA256(e.key))
+}
+
+func defaultKnownHostsFile() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".ssh", "known_hosts")
+}
+
+func (opts *sshOpts) knownHostsFile() string {
+	if opts.KnownHosts != "" {
+		return opts.KnownHosts
+	}
+	return defaultKnownHostsFile()
+}
+
+func fingerprintCallback(fingerprint string) ssh.HostKeyCallback {
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		if ssh.FingerprintSHA256(key) == fingerprint || ssh.FingerprintLegacyMD5(key) == strings.TrimPrefix(fingerprint, "MD5:") {
+			return nil
+		}
+		return &hostKeyError{host: hostname, key: key, problem: fmt.Sprintf("host key does not match pinned fingerprint %s", fingerprint)}
+	}
+}
+
+func knownHostsCallback(file, policy string) (ssh.HostKeyCallback, error) {
+	if file == "" {
+		return nil, errors.New("cannot locate known_hosts file, use --known-hosts")
+	}
+	if policy == hostKeyPolicyAcceptNew {
+		if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+			return nil, err
+		}
+		f, err := os.OpenFile(file, os.O_CREATE|os.O_RDONLY, 0600)
+		if err != nil {
+			return nil, err
+		}
+		f.Close()
+	}
+
+	callback, err := knownhosts.New(file)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		err := callback(hostname, remote, key)
+		var keyErr *knownhosts.KeyError
+		if !errors.As(err, &keyErr) {
+			return err
+		}
+		if len(keyErr.Want) > 0 {
+			return &hostKeyError{host: hostname, key: key, problem: "host key mismatch"}
+		}
+		if policy != hostKeyPolicyAcceptNew {
+			return &hostKeyError{host: hostname, key: key, problem: fmt.Sprintf("unknown host key (not in %s)", file)}
+		}
+		return appendKnownHost(file, hostname, key)
+	}, nil
+}
+
+func appendKnownHost(file, hostname string, key ssh.PublicKey) error {
+	f, err := os.OpenFile(file, os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = fmt.Fprintln(f, knownhosts.Line([]string{knownhosts.Normalize(hostname)}, key))
+	return err
+}
+
+// knownHostAlgorithms returns the host key algorithms already recorded for
+// addr, so that the server is asked for a key we can actually verify.
+func knownHostAlgorithms(callback ssh.HostKeyCallback, addr string) []string {
+	probe, err := ssh.NewPublicKey(ed25519.NewKeyFromSeed(make([]byte, ed25519.SeedSize)).Public())
+	if err != nil {
+		return nil
+	}
+
+	var keyErr *knownhosts.KeyError
+	if err := callback(addr, &net.TCPAddr{IP: net.IPv4zero}, probe); !errors.As(err, &keyErr) {
+		return nil
+	}
+
+	var algorithms []string
+	for _, known := range keyErr.Want {
+		switch known.Key.Type() {
+		case ssh.KeyAlgoRSA:
+			algorithms = append(algorithms, ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA256, ssh.KeyAlgoRSA)
+		default:
+			algorithms = append(algorithms, known.Key.Type())
+		}
+	}
+	return algorithms
+}
+
+// hostKeyConfig builds the host key verification for addr. A non empty
+// fingerprint pins the key and bypasses known hosts.
+func (opts *sshOpts) hostKeyConfig(addr, fingerprint string) (ssh.HostKeyCallback, []string, error) {
+	if fingerprint != "" {
+		return fingerprintCallback(fingerprint), nil, nil
+	}
+
+	switch opts.HostKeyPolicy {
+	case hostKeyPolicyOff:
+		return ssh.InsecureIgnoreHostKey(), nil, nil
+	case hostKeyPolicyStrict, hostKeyPolicyAcceptNew:
+		file := opts.knownHostsFile()
+		callback, err := knownHostsCallback(file, opts.HostKeyPolicy)
+		if err != nil {
+			return nil, nil, err
+		}
+		// The probe must not go through the accept-new wrapper, or it would
+		// record the placeholder key.
+		probe, err := knownhosts.New(file)
+		if err != nil {
+			return nil, nil, err
+		}
+		return callback, knownHostAlgorithms(probe, addr), nil
+	default:
+		return nil, nil, fmt.Errorf("unknown host key policy: %s", opts.HostKeyPolicy)
+	}
+}
@@ -0,0 +1,145 @@
+package checkdifftime
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/mackerelio/checkers"
+)
+
+type hostResult struct {
+	host        string
+	status      checkers.Status
+	message     string
+	unreachable bool
+}
+
+// splitHostPort splits the host[:port] accepted by -H, --hosts-file and
+// --reference, an IPv6 address being written [::1]:2222. The port is 0
+// when not given.
+func splitHostPort(spec string) (string, int) {
+	if host, port, err := net.SplitHostPort(spec); err == nil {
+		if n, err := strconv.Atoi(port); err == nil {
+			return host, n
+		}
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(spec, "["), "]"), 0
+}
+
+func readHostsFile(file string) ([]string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var hosts []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		hosts = append(hosts, line)
+	}
+	return hosts, scanner.Err()
+}
+
+func (opts *sshOpts) hosts() ([]string, error) {
+	hosts := append([]string{}, opts.Hostname...)
+	if opts.HostsFile != "" {
+		fromFile, err := readHostsFile(opts.HostsFile)
+		if err != nil {
+			return nil, err
+		}
+		hosts = append(hosts, fromFile...)
+	}
+
+	if len(hosts) == 0 {
+		if opts.HostsFile != "" {
+			return nil, fmt.Errorf("no host found in %s", opts.HostsFile)
+		}
+		hosts = append(hosts, "localhost")
+	}
+	return hosts, nil
+}
+
+func (opts *sshOpts) checkHost(host string) hostResult {
+	target := opts.target(host)
+	m, err := target.measure()
+	if err != nil {
+		return hostResult{host: host, status: statusOf(err), message: err.Error(), unreachable: isUnreachable(err)}
+	}
+	message := fmt.Sprintf("%+.3fs", m.offset().Seconds())
+	if len(m.alerts) > 0 {
+		message += " (" + strings.Join(m.alerts, ", ") + ")"
+	}
+	return hostResult{host: host, status: opts.evaluate(m), message: message}
+}
+
+func (opts *sshOpts) checkHosts(hosts []string) *checkers.Checker {
+	concurrency := opts.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	results := make([]hostResult, len(hosts))
+	semaphore := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, host := range hosts {
+		wg.Add(1)
+		go func(i int, host string) {
+			defer wg.Done()
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+			results[i] = opts.checkHost(host)
+		}(i, host)
+	}
+	wg.Wait()
+
+	return checkers.NewChecker(aggregateStatus(results), buildHostsMessage(results))
+}
+
+func aggregateStatus(results []hostResult) checkers.Status {
+	status := checkers.OK
+	for _, r := range results {
+		status = worse(status, r.status)
+	}
+	return status
+}
+
+func buildHostsMessage(results []hostResult) string {
+	counts := map[checkers.Status]int{}
+	var unreachableHosts []string
+	maxLength := 0
+	for _, r := range results {
+		counts[r.status]++
+		if r.unreachable {
+			unreachableHosts = append(unreachableHosts, r.host)
+		}
+		if len(r.host) > maxLength {
+			maxLength = len(r.host)
+		}
+	}
+
+	message := fmt.Sprintf("%d hosts", len(results))
+	for _, status := range []checkers.Status{checkers.CRITICAL, checkers.UNKNOWN, checkers.WARNING, checkers.OK} {
+		if counts[status] > 0 {
+			message += fmt.Sprintf(" - %s: %d", status, counts[status])
+		}
+	}
+	if len(unreachableHosts) > 0 {
+		message += " - Unreachable: " + strings.Join(unreachableHosts, ", ")
+	}
+
+	message += "\n"
+	for _, r := range results {
+		message += fmt.Sprintf("\n%-*s : %-8s %s", maxLength, r.host, r.status, r.message)
+	}
+	return message
+}
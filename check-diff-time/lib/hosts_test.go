@@ -0,0 +1,70 @@
+package checkdifftime
+
+import (
+	"net"
+	"testing"
+
+	"github.com/mackerelio/checkers"
+)
+
+func TestTargetPort(t *testing.T) {
+	opts := &sshOpts{Port: 2200}
+	tests := []struct {
+		spec string
+		host string
+		port int
+	}{
+		{"db1", "db1", 2200},
+		{"db1:2222", "db1", 2222},
+		{"10.0.0.4:22", "10.0.0.4", 22},
+		{"[2001:db8::4]:2222", "2001:db8::4", 2222},
+		{"[2001:db8::4]", "2001:db8::4", 2200},
+		{"2001:db8::4", "2001:db8::4", 2200},
+	}
+	for _, tt := range tests {
+		target := opts.target(tt.spec)
+		if target.host != tt.host || target.Port != tt.port {
+			t.Errorf("target(%q) = %s port %d, want %s port %d", tt.spec, target.host, target.Port, tt.host, tt.port)
+		}
+	}
+}
+
+func TestCheckHostUnreachable(t *testing.T) {
+	// a server which closes the connection after its banner
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Write([]byte("SSH-2.0-OpenSSH_9.2\r\n"))
+			conn.Close()
+		}
+	}()
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed.Close()
+
+	opts := &sshOpts{Mode: "ssh", Timeout: 2, Samples: 1, User: "nobody", Password: "secret", NoAgent: true, HostKeyPolicy: "off"}
+	tests := []struct {
+		name        string
+		addr        string
+		unreachable bool
+	}{
+		{"handshake failure", listener.Addr().String(), false},
+		{"connection refused", closed.Addr().String(), true},
+	}
+	for _, tt := range tests {
+		r := opts.checkHost(tt.addr)
+		if r.status != checkers.CRITICAL || r.unreachable != tt.unreachable {
+			t.Errorf("%s: got %s, unreachable %v (%s), want CRITICAL, unreachable %v", tt.name, r.status, r.unreachable, r.message, tt.unreachable)
+		}
+	}
+}
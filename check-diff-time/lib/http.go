@@ -0,0 +1,98 @@
+package checkdifftime
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/http/httptrace"
+	"time"
+)
+
+// secondResolution is added to the times servers only give to the second
+// (HTTP Date header, TLS gmt_unix_time) so that they point to the middle of
+// the second rather than its start.
+const secondResolution = time.Second / 2
+
+func (opts *sshOpts) httpURL() string {
+	scheme := "https"
+	if opts.PlainHTTP {
+		scheme = "http"
+	}
+	return scheme + "://" + opts.addr() + opts.HTTPPath
+}
+
+func (opts *sshOpts) httpClient() *http.Client {
+	return &http.Client{
+		Transport: &http.Transport{
+			// no proxy: the Date header must come from the server itself
+			Proxy:           nil,
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: opts.Insecure},
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// sampleHTTP sends a HEAD request and compares the Date header with the
+// midpoint between writing the request and receiving the first byte of the
+// response, which leaves out the connection setup.
+func sampleHTTP(d *deadline, client *http.Client, url string) (sample, *http.Response, error) {
+	var sent, received time.Time
+	trace := &httptrace.ClientTrace{
+		ConnectStart:         func(string, string) { d.enter(phaseConnect) },
+		TLSHandshakeStart:    func() { d.enter(phaseHandshake) },
+		GotConn:              func(httptrace.GotConnInfo) { d.enter(phaseRequest) },
+		WroteRequest:         func(httptrace.WroteRequestInfo) { sent = time.Now() },
+		GotFirstResponseByte: func() { received = time.Now() },
+	}
+
+	req, err := http.NewRequestWithContext(httptrace.WithClientTrace(d.ctx, trace), http.MethodHead, url, nil)
+	if err != nil {
+		return sample{}, nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return sample{}, nil, d.wrap(unreachable(err))
+	}
+	resp.Body.Close()
+
+	date := resp.Header.Get("Date")
+	if date == "" {
+		return sample{}, resp, fmt.Errorf("no Date header in response from %s", url)
+	}
+	remote, err := http.ParseTime(date)
+	if err != nil {
+		return sample{}, resp, fmt.Errorf("cannot parse Date header %q", date)
+	}
+	return sample{local: midpoint(sent, received), remote: remote.Add(secondResolution), delay: received.Sub(sent)}, resp, nil
+}
+
+func (opts *sshOpts) measureHTTP(d *deadline) (*measurement, error) {
+	client := opts.httpClient()
+	defer client.CloseIdleConnections()
+
+	url := opts.httpURL()
+	samples := make([]sample, 0, opts.Samples)
+	var resp *http.Response
+	for i := 0; i < opts.Samples; i++ {
+		s, r, err := sampleHTTP(d, client, url)
+		if err != nil {
+			return nil, err
+		}
+		samples = append(samples, s)
+		resp = r
+	}
+	result := averageSamples(samples)
+	if err := opts.rebase(&result); err != nil {
+		return nil, err
+	}
+
+	m := &measurement{sample: result}
+	m.add("%s: %s", opts.baselineLabel(), formatDate(result.local))
+	m.add("Server date: %s", formatDate(result.remote))
+	m.add("Diff time: %s", describeOffset(result.offset()))
+	m.add("Round trip: %s", result.delay)
+	m.add("HTTP status: %s", resp.Status)
+	return m, nil
+}
@@ -0,0 +1,78 @@
+package checkdifftime
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// skewedServer answers with the Date header of a clock skewed by skew, or
+// with no Date header at all when skew is nil.
+func skewedServer(skew *time.Duration) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if skew == nil {
+			w.Header()["Date"] = nil
+		} else {
+			w.Header().Set("Date", time.Now().Add(*skew).UTC().Format(http.TimeFormat))
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+func httpTarget(t *testing.T, server *httptest.Server) *sshOpts {
+	t.Helper()
+	host, port, err := net.SplitHostPort(server.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := &sshOpts{Mode: "http", Timeout: 2, Samples: 2, HTTPPath: "/", Insecure: true}
+	opts.Port, _ = strconv.Atoi(port)
+	return opts.target(host)
+}
+
+func TestMeasureHTTP(t *testing.T) {
+	skew := -42 * time.Second
+	for _, tls := range []bool{false, true} {
+		var server *httptest.Server
+		if tls {
+			server = httptest.NewTLSServer(skewedServer(&skew))
+		} else {
+			server = httptest.NewServer(skewedServer(&skew))
+		}
+		defer server.Close()
+
+		opts := httpTarget(t, server)
+		opts.PlainHTTP = !tls
+		d, cancel := opts.newDeadline()
+		m, err := opts.measureHTTP(d)
+		cancel()
+		if err != nil {
+			t.Fatalf("tls %v: %s", tls, err)
+		}
+		// the Date header only has a one second resolution
+		if diff := m.offset() - skew; diff < -time.Second || diff > time.Second {
+			t.Errorf("tls %v: got offset %s, want %s", tls, m.offset(), skew)
+		}
+		if !strings.Contains(m.String(), "HTTP status: 204 No Content") {
+			t.Errorf("tls %v: no HTTP status in %q", tls, m.String())
+		}
+	}
+}
+
+func TestMeasureHTTPWithoutDate(t *testing.T) {
+	server := httptest.NewServer(skewedServer(nil))
+	defer server.Close()
+
+	opts := httpTarget(t, server)
+	opts.PlainHTTP = true
+	d, cancel := opts.newDeadline()
+	defer cancel()
+	_, err := opts.measureHTTP(d)
+	if err == nil || !strings.Contains(err.Error(), "no Date header in response") {
+		t.Fatalf("got error %v, want a missing Date header", err)
+	}
+}
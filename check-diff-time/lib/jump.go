@@ -0,0 +1,189 @@
+package checkdifftime
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// endpoint is an SSH server to connect to, either the target or a jump host.
+type endpoint struct {
+	user     string
+	host     string
+	port     int
+	identity string
+}
+
+func (e endpoint) addr() string {
+	return net.JoinHostPort(e.host, strconv.Itoa(e.port))
+}
+
+func (e endpoint) String() string {
+	if e.user == "" {
+		return e.addr()
+	}
+	return e.user + "@" + e.addr()
+}
+
+// parseEndpoint parses [user@]host[:port] as accepted by ssh -J. The user
+// and port are left empty when not given.
+func parseEndpoint(spec string) (endpoint, error) {
+	e := endpoint{}
+
+	if at := strings.LastIndex(spec, "@"); at >= 0 {
+		e.user = spec[:at]
+		spec = spec[at+1:]
+	}
+
+	host, port, err := net.SplitHostPort(spec)
+	if err != nil {
+		// no port given, strip the brackets of a bare IPv6 address
+		e.host = strings.TrimSuffix(strings.TrimPrefix(spec, "["), "]")
+	} else {
+		e.host = host
+		e.port, err = strconv.Atoi(port)
+		if err != nil {
+			return e, fmt.Errorf("invalid port in jump host %q", spec)
+		}
+	}
+
+	if e.host == "" {
+		return e, fmt.Errorf("invalid jump host %q", spec)
+	}
+	return e, nil
+}
+
+// jumpEndpoint resolves one hop: values given in the spec win over
+// ssh_config ones, which win over the command line defaults.
+func (opts *sshOpts) jumpEndpoint(spec string) (endpoint, error) {
+	e, err := parseEndpoint(spec)
+	if err != nil {
+		return e, err
+	}
+
+	if opts.sshConfig != nil {
+		hc, err := resolveHost(opts.sshConfig, e.host)
+		if err != nil {
+			return e, err
+		}
+		e.host = hc.hostname
+		if e.port == 0 {
+			e.port = hc.port
+		}
+		if e.user == "" {
+			e.user = hc.user
+		}
+		e.identity = hc.identity
+	}
+
+	if e.user == "" {
+		e.user = opts.User
+	}
+	if e.port == 0 {
+		e.port = 22
+	}
+	if e.identity == "" {
+		e.identity = opts.IdentityFile
+	}
+	return e, nil
+}
+
+// route returns the target endpoint and the jump hosts leading to it.
+// Explicit options override the ssh_config values of the target.
+func (opts *sshOpts) route() (endpoint, []endpoint, error) {
+	target := endpoint{user: opts.User, host: opts.host, port: opts.Port, identity: opts.IdentityFile}
+	jumps := opts.Jump
+
+	if opts.sshConfig != nil {
+		hc, err := resolveHost(opts.sshConfig, opts.host)
+		if err != nil {
+			return target, nil, err
+		}
+		target.host = hc.hostname
+		if target.port == 0 {
+			target.port = hc.port
+		}
+		if !opts.userSet && hc.user != "" {
+			target.user = hc.user
+		}
+		if target.identity == "" {
+			target.identity = hc.identity
+		}
+		if len(jumps) == 0 && hc.proxyJump != "" {
+			jumps = []string{hc.proxyJump}
+		}
+	}
+	if target.port == 0 {
+		target.port = opts.port()
+	}
+
+	// --jump may be repeated and each value may itself be a comma separated chain
+	var hops []endpoint
+	for _, spec := range jumps {
+		for _, hop := range strings.Split(spec, ",") {
+			e, err := opts.jumpEndpoint(strings.TrimSpace(hop))
+			if err != nil {
+				return target, nil, err
+			}
+			hops = append(hops, e)
+		}
+	}
+	return target, hops, nil
+}
+
+// sshConnection is a client connected to the target, possibly tunnelled
+// through jump hosts.
+type sshConnection struct {
+	*ssh.Client
+	auth    *sshAuth
+	clients []*ssh.Client
+	auths   []*sshAuth
+}
+
+// Close tears down the target connection then every hop, last one first.
+func (c *sshConnection) Close() {
+	for i := len(c.clients) - 1; i >= 0; i-- {
+		c.clients[i].Close()
+	}
+	for _, auth := range c.auths {
+		auth.Close()
+	}
+}
+
+func (opts *sshOpts) connect(d *deadline) (*sshConnection, error) {
+	target, hops, err := opts.route()
+	if err != nil {
+		return nil, err
+	}
+
+	conn := &sshConnection{}
+	var via *ssh.Client
+	for _, hop := range hops {
+		client, auth, err := opts.dialEndpoint(d, via, hop, "")
+		if auth != nil {
+			conn.auths = append(conn.auths, auth)
+		}
+		if err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("jump host %s: %w", hop, err)
+		}
+		conn.clients = append(conn.clients, client)
+		via = client
+	}
+
+	client, auth, err := opts.dialEndpoint(d, via, target, opts.HostKeyFingerprint)
+	if auth != nil {
+		conn.auths = append(conn.auths, auth)
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	conn.clients = append(conn.clients, client)
+	conn.Client = client
+	conn.auth = auth
+	return conn, nil
+}
@@ -0,0 +1,63 @@
+package checkdifftime
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/mackerelio/checkers"
+)
+
+// localClock is the synchronisation state the kernel keeps for the local
+// clock, as reported by adjtimex.
+type localClock struct {
+	synchronized bool
+	// state is the clock state returned by adjtimex, e.g. TIME_OK
+	state          string
+	estimatedError time.Duration
+	maximumError   time.Duration
+	// frequency is the frequency correction applied to the clock, in ppm
+	frequency float64
+}
+
+func checkClockError(value time.Duration, warning, critical float64) checkers.Status {
+	seconds := math.Abs(value.Seconds())
+	if seconds >= critical {
+		return checkers.CRITICAL
+	}
+	if seconds >= warning {
+		return checkers.WARNING
+	}
+	return checkers.OK
+}
+
+// measureLocal needs no network: it reports whether the kernel considers
+// the local clock disciplined and how large it estimates the error.
+func (opts *sshOpts) measureLocal(d *deadline) (*measurement, error) {
+	clock, err := readLocalClock()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	m := &measurement{sample: sample{local: now, remote: now}}
+	synchronized := "no"
+	if clock.synchronized {
+		synchronized = "yes"
+	}
+	m.add("Synchronized: %s (adjtimex, %s)", synchronized, clock.state)
+	m.add("Est. error: %s", clock.estimatedError)
+	m.add("Max. error: %s", clock.maximumError)
+	m.add("Frequency: %+.3f ppm", clock.frequency)
+
+	if !clock.synchronized {
+		m.raise(checkers.WARNING, "not synchronized")
+	}
+	if status := checkClockError(clock.estimatedError, opts.WarningEstError, opts.CriticalEstError); status != checkers.OK {
+		m.raise(status, fmt.Sprintf("estimated error %s", clock.estimatedError))
+	}
+	if status := checkClockError(clock.maximumError, opts.WarningMaxError, opts.CriticalMaxError); status != checkers.OK {
+		m.raise(status, fmt.Sprintf("maximum error %s", clock.maximumError))
+	}
+	return m, nil
+}
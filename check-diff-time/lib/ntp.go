@@ -0,0 +1,180 @@
+package checkdifftime
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+const (
+	ntpPacketSize = 48
+	// seconds between the NTP epoch (1900) and the Unix epoch (1970)
+	ntpEpochOffset = 2208988800
+
+	ntpVersion    = 4
+	ntpModeClient = 3
+	ntpModeServer = 4
+
+	ntpLeapAlarm       = 3
+	ntpMaxStratum      = 16
+	ntpKissOfDeathType = 0
+)
+
+type ntpResponse struct {
+	leap           uint8
+	stratum        uint8
+	referenceID    [4]byte
+	rootDelay      time.Duration
+	rootDispersion time.Duration
+	offset         time.Duration
+	delay          time.Duration
+}
+
+func toNTPTime(t time.Time) uint64 {
+	nanos := uint64(t.UnixNano()) + ntpEpochOffset*uint64(time.Second)
+	seconds := nanos / uint64(time.Second)
+	fraction := (nanos % uint64(time.Second)) << 32 / uint64(time.Second)
+	return seconds<<32 | fraction
+}
+
+func fromNTPTime(ts uint64) time.Time {
+	seconds := int64(ts>>32) - ntpEpochOffset
+	// NTP era 1 starts in February 2036
+	if seconds < -ntpEpochOffset/2 {
+		seconds += 1 << 32
+	}
+	nanos := (int64(ts&0xffffffff) * int64(time.Second)) >> 32
+	return time.Unix(seconds, nanos)
+}
+
+func fromNTPShort(v uint32) time.Duration {
+	return time.Duration((int64(v) * int64(time.Second)) >> 16)
+}
+
+func (r *ntpResponse) leapString() string {
+	switch r.leap {
+	case 0:
+		return "none"
+	case 1:
+		return "insert second"
+	case 2:
+		return "delete second"
+	default:
+		return "unsynchronized"
+	}
+}
+
+func (r *ntpResponse) kissCode() string {
+	return strings.TrimRight(string(r.referenceID[:]), "\x00")
+}
+
+func (r *ntpResponse) referenceString() string {
+	if r.stratum <= 1 {
+		return r.kissCode()
+	}
+	return net.IP(r.referenceID[:]).String()
+}
+
+func queryNTP(ctx context.Context, addr string) (*ntpResponse, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			return nil, err
+		}
+	}
+
+	request := make([]byte, ntpPacketSize)
+	request[0] = ntpVersion<<3 | ntpModeClient
+
+	t1 := time.Now()
+	transmit := toNTPTime(t1)
+	binary.BigEndian.PutUint64(request[40:], transmit)
+	if _, err := conn.Write(request); err != nil {
+		return nil, err
+	}
+
+	response := make([]byte, ntpPacketSize)
+	for {
+		n, err := conn.Read(response)
+		if err != nil {
+			return nil, err
+		}
+		t4 := time.Now()
+		if n < ntpPacketSize {
+			continue
+		}
+		// a reply which does not echo our transmit timestamp is stale or forged
+		if binary.BigEndian.Uint64(response[24:]) != transmit {
+			continue
+		}
+		return parseNTPResponse(response, t1, t4)
+	}
+}
+
+func parseNTPResponse(b []byte, t1, t4 time.Time) (*ntpResponse, error) {
+	if mode := b[0] & 0x7; mode != ntpModeServer {
+		return nil, fmt.Errorf("unexpected NTP mode %d in response", mode)
+	}
+
+	r := &ntpResponse{
+		leap:           b[0] >> 6,
+		stratum:        b[1],
+		rootDelay:      fromNTPShort(binary.BigEndian.Uint32(b[4:])),
+		rootDispersion: fromNTPShort(binary.BigEndian.Uint32(b[8:])),
+	}
+	copy(r.referenceID[:], b[12:16])
+
+	received := binary.BigEndian.Uint64(b[32:])
+	transmitted := binary.BigEndian.Uint64(b[40:])
+	if transmitted == 0 {
+		return nil, errors.New("NTP response has no transmit timestamp")
+	}
+
+	t2 := fromNTPTime(received)
+	t3 := fromNTPTime(transmitted)
+	r.offset = (t2.Sub(t1) + t3.Sub(t4)) / 2
+	r.delay = t4.Sub(t1) - t3.Sub(t2)
+	return r, nil
+}
+
+func (opts *sshOpts) measureNTP(d *deadline) (*measurement, error) {
+	d.enter(phaseNTPQuery)
+	r, err := queryNTP(d.ctx, opts.addr())
+	if err != nil {
+		return nil, d.wrap(unreachable(err))
+	}
+
+	if r.stratum == ntpKissOfDeathType {
+		return nil, critical(fmt.Errorf("Kiss-of-death received from %s: %s", opts.host, r.kissCode()))
+	}
+	if r.leap == ntpLeapAlarm || r.stratum >= ntpMaxStratum {
+		return nil, critical(fmt.Errorf("%s is not synchronized (leap: %s, stratum: %d)", opts.host, r.leapString(), r.stratum))
+	}
+
+	now := time.Now()
+	result := sample{local: now, remote: now.Add(r.offset), delay: r.delay}
+	if err := opts.rebase(&result); err != nil {
+		return nil, err
+	}
+
+	m := &measurement{sample: result}
+	m.add("Offset: %s", describeOffset(result.offset()))
+	if opts.reference != nil {
+		m.add("Baseline: %s", opts.reference.name)
+	}
+	m.add("Delay: %s", r.delay)
+	m.add("Stratum: %d", r.stratum)
+	m.add("Reference: %s", r.referenceString())
+	m.add("Leap: %s", r.leapString())
+	return m, nil
+}
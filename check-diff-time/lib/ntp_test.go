@@ -0,0 +1,179 @@
+package checkdifftime
+
+import (
+	"encoding/binary"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mackerelio/checkers"
+)
+
+// ntpReply builds the reply of a server whose clock is skewed by skew to
+// the given request.
+func ntpReply(request []byte, skew time.Duration, leap, stratum uint8, referenceID string) []byte {
+	now := toNTPTime(time.Now().Add(skew))
+	b := make([]byte, ntpPacketSize)
+	b[0] = leap<<6 | ntpVersion<<3 | ntpModeServer
+	b[1] = stratum
+	copy(b[12:16], referenceID)
+	copy(b[24:32], request[40:48])
+	binary.BigEndian.PutUint64(b[32:], now)
+	binary.BigEndian.PutUint64(b[40:], now)
+	return b
+}
+
+// ntpResponder answers the first request it receives with the packets
+// returned by reply and returns the port it listens on.
+func ntpResponder(t *testing.T, reply func(request []byte) [][]byte) int {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	go func() {
+		request := make([]byte, ntpPacketSize)
+		n, addr, err := conn.ReadFrom(request)
+		if err != nil || n != ntpPacketSize {
+			return
+		}
+		for _, packet := range reply(request) {
+			conn.WriteTo(packet, addr)
+		}
+	}()
+	return conn.LocalAddr().(*net.UDPAddr).Port
+}
+
+func measureNTPWith(t *testing.T, reply func(request []byte) [][]byte) (*measurement, error) {
+	t.Helper()
+	opts := &sshOpts{Mode: "ntp", Timeout: 1, Port: ntpResponder(t, reply)}
+	opts = opts.target("127.0.0.1")
+	d, cancel := opts.newDeadline()
+	defer cancel()
+	return opts.measureNTP(d)
+}
+
+func TestMeasureNTP(t *testing.T) {
+	skew := 2500 * time.Millisecond
+	tests := []struct {
+		name   string
+		reply  func(request []byte) [][]byte
+		status checkers.Status
+		err    string
+	}{
+		{
+			name: "skewed",
+			reply: func(request []byte) [][]byte {
+				return [][]byte{ntpReply(request, skew, 0, 2, "\x0a\x00\x00\x01")}
+			},
+		},
+		{
+			name: "leap alarm",
+			reply: func(request []byte) [][]byte {
+				return [][]byte{ntpReply(request, 0, ntpLeapAlarm, 2, "\x0a\x00\x00\x01")}
+			},
+			status: checkers.CRITICAL,
+			err:    "not synchronized (leap: unsynchronized, stratum: 2)",
+		},
+		{
+			name: "stratum 16",
+			reply: func(request []byte) [][]byte {
+				return [][]byte{ntpReply(request, 0, 0, ntpMaxStratum, "INIT")}
+			},
+			status: checkers.CRITICAL,
+			err:    "not synchronized (leap: none, stratum: 16)",
+		},
+		{
+			name: "kiss of death",
+			reply: func(request []byte) [][]byte {
+				return [][]byte{ntpReply(request, 0, 0, ntpKissOfDeathType, "RATE")}
+			},
+			status: checkers.CRITICAL,
+			err:    "Kiss-of-death received from 127.0.0.1: RATE",
+		},
+		{
+			name: "not echoed then echoed",
+			reply: func(request []byte) [][]byte {
+				forged := ntpReply(request, time.Hour, 0, 2, "\x0a\x00\x00\x01")
+				binary.BigEndian.PutUint64(forged[24:], 42)
+				return [][]byte{forged, ntpReply(request, skew, 0, 2, "\x0a\x00\x00\x01")}
+			},
+		},
+		{
+			name: "never echoed",
+			reply: func(request []byte) [][]byte {
+				forged := ntpReply(request, skew, 0, 2, "\x0a\x00\x00\x01")
+				binary.BigEndian.PutUint64(forged[24:], 42)
+				return [][]byte{forged}
+			},
+			status: checkers.UNKNOWN,
+			err:    "timeout after 1s during ntp query",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := measureNTPWith(t, tt.reply)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("got error %v, want %q", err, tt.err)
+				}
+				if got := statusOf(err); got != tt.status {
+					t.Errorf("got status %s, want %s", got, tt.status)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := m.offset() - skew; diff < -50*time.Millisecond || diff > 50*time.Millisecond {
+				t.Errorf("got offset %s, want %s", m.offset(), skew)
+			}
+		})
+	}
+}
+
+func TestParseNTPResponse(t *testing.T) {
+	t1 := time.Unix(1760781600, 0)
+	t4 := t1.Add(100 * time.Millisecond)
+
+	b := make([]byte, ntpPacketSize)
+	b[0] = 1<<6 | ntpVersion<<3 | ntpModeServer
+	b[1] = 1
+	binary.BigEndian.PutUint32(b[4:], 1<<15) // 0.5s
+	copy(b[12:16], "GPS\x00")
+	// the server clock is 10s ahead and holds the request for 20ms
+	binary.BigEndian.PutUint64(b[32:], toNTPTime(t1.Add(10*time.Second+40*time.Millisecond)))
+	binary.BigEndian.PutUint64(b[40:], toNTPTime(t1.Add(10*time.Second+60*time.Millisecond)))
+
+	r, err := parseNTPResponse(b, t1, t4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.offset.Round(time.Millisecond) != 10*time.Second {
+		t.Errorf("got offset %s, want 10s", r.offset)
+	}
+	if r.delay.Round(time.Millisecond) != 80*time.Millisecond {
+		t.Errorf("got delay %s, want 80ms", r.delay)
+	}
+	if r.rootDelay != 500*time.Millisecond {
+		t.Errorf("got root delay %s, want 500ms", r.rootDelay)
+	}
+	if r.referenceString() != "GPS" || r.leapString() != "insert second" {
+		t.Errorf("got reference %q and leap %q", r.referenceString(), r.leapString())
+	}
+
+	b[0] = ntpVersion<<3 | ntpModeClient
+	if _, err := parseNTPResponse(b, t1, t4); err == nil {
+		t.Error("a client mode packet was accepted")
+	}
+
+	b[0] = ntpVersion<<3 | ntpModeServer
+	binary.BigEndian.PutUint64(b[40:], 0)
+	if _, err := parseNTPResponse(b, t1, t4); err == nil {
+		t.Error("a response without transmit timestamp was accepted")
+	}
+}
@@ -0,0 +1,74 @@
+package checkdifftime
+
+import (
+	"fmt"
+)
+
+// referenceClock is the time source used as the baseline instead of the
+// local clock. It is measured once, concurrently with the targets, and
+// both offsets are taken against the local clock which only bridges the
+// short interval between the two samples.
+type referenceClock struct {
+	name string
+	done chan struct{}
+	m    *measurement
+	err  error
+}
+
+func (opts *sshOpts) referenceMode() string {
+	if opts.ReferenceMode != "" {
+		return opts.ReferenceMode
+	}
+	return opts.Mode
+}
+
+func (opts *sshOpts) startReference() *referenceClock {
+	ref := &referenceClock{name: opts.Reference, done: make(chan struct{})}
+
+	// --port is meant for the targets, not for the reference
+	host, port := splitHostPort(opts.Reference)
+	r := opts.target(host)
+	r.Mode = opts.referenceMode()
+	r.Port = port
+	// the pin belongs to the targets, the reference goes through known hosts
+	r.HostKeyFingerprint = ""
+	r.SyncStatus = false
+	r.RTC = false
+	r.reference = nil
+	r.drift = nil
+
+	go func() {
+		defer close(ref.done)
+		ref.m, ref.err = r.measure()
+	}()
+	return ref
+}
+
+func (ref *referenceClock) wait() (*measurement, error) {
+	<-ref.done
+	return ref.m, ref.err
+}
+
+// rebase turns a sample taken against the local clock into one taken
+// against the reference clock, if any.
+func (opts *sshOpts) rebase(s *sample) error {
+	if opts.reference == nil {
+		return nil
+	}
+
+	m, err := opts.reference.wait()
+	if err != nil {
+		// the target cannot be judged, whatever happened to the reference
+		return fmt.Errorf("reference %s: %s", opts.reference.name, err)
+	}
+
+	s.local = s.local.Add(m.offset())
+	return nil
+}
+
+func (opts *sshOpts) baselineLabel() string {
+	if opts.reference != nil {
+		return "Reference date (" + opts.reference.name + ")"
+	}
+	return "Current date"
+}
@@ -0,0 +1,122 @@
+package checkdifftime
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	formatEpoch         = "epoch"
+	formatEpochFraction = "epoch-fraction"
+	formatRFC3339       = "rfc3339"
+	formatLayout        = "layout"
+)
+
+// remoteDateParser turns the output of the remote command into a time.
+type remoteDateParser func(output string) (time.Time, error)
+
+// dateParser returns the parser selected by --remote-format.
+func (opts *sshOpts) dateParser() (remoteDateParser, error) {
+	switch opts.RemoteFormat {
+	case formatEpoch:
+		return parseEpochSeconds, nil
+	case formatRFC3339:
+		return parseRFC3339, nil
+	case formatLayout:
+		if opts.RemoteLayout == "" {
+			return nil, errors.New("--remote-layout is required with --remote-format layout")
+		}
+		loc, err := time.LoadLocation(opts.RemoteTimezone)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --remote-timezone: %s", err)
+		}
+		return layoutParser(opts.RemoteLayout, loc), nil
+	}
+	return parseEpoch, nil
+}
+
+// remoteCommands are the commands printing the time in each format.
+var remoteCommands = map[string]string{
+	formatEpochFraction: "date +%s.%N",
+	formatEpoch:         "date +%s",
+	formatRFC3339:       "date --rfc-3339=ns",
+}
+
+// defaultRemoteCommand picks the command matching --remote-format when
+// --remote-command is not given.
+func (opts *sshOpts) defaultRemoteCommand() error {
+	if opts.RemoteCommand != "" {
+		return nil
+	}
+	command, ok := remoteCommands[opts.RemoteFormat]
+	if !ok {
+		return fmt.Errorf("--remote-command is required with --remote-format %s", opts.RemoteFormat)
+	}
+	opts.RemoteCommand = command
+	return nil
+}
+
+// parseEpoch parses epoch seconds with an optional fraction, as printed by
+// date +%s.%N. Implementations of date without %N support print the
+// directive literally, which is tolerated.
+func parseEpoch(output string) (time.Time, error) {
+	value := strings.TrimSpace(output)
+	secondsPart, fractionPart, _ := strings.Cut(value, ".")
+
+	seconds, err := strconv.ParseInt(secondsPart, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("cannot parse remote date %q as epoch", output)
+	}
+
+	var nanos int64
+	if fractionPart != "" && strings.Trim(fractionPart, "0123456789") == "" {
+		if len(fractionPart) > 9 {
+			fractionPart = fractionPart[:9]
+		}
+		nanos, err = strconv.ParseInt(fractionPart+strings.Repeat("0", 9-len(fractionPart)), 10, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("cannot parse remote date %q as epoch", output)
+		}
+	}
+	return time.Unix(seconds, nanos), nil
+}
+
+// parseEpochSeconds parses whole epoch seconds, as printed by date +%s. The
+// result points to the middle of the second.
+func parseEpochSeconds(output string) (time.Time, error) {
+	seconds, err := strconv.ParseInt(strings.TrimSpace(output), 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("cannot parse remote date %q as epoch seconds", output)
+	}
+	return time.Unix(seconds, 0).Add(secondResolution), nil
+}
+
+func parseRFC3339(output string) (time.Time, error) {
+	value := strings.TrimSpace(output)
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		// date --rfc-3339 separates the date and the time with a space
+		t, err = time.Parse(time.RFC3339Nano, strings.Replace(value, " ", "T", 1))
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("cannot parse remote date %q as RFC 3339", output)
+	}
+	return t, nil
+}
+
+// layoutParser parses a Go time layout, in loc when the layout has no
+// zone. The leading '*' or '.' that network devices print when their clock
+// is not synchronized or not authoritative (show clock) are ignored.
+func layoutParser(layout string, loc *time.Location) remoteDateParser {
+	return func(output string) (time.Time, error) {
+		value := strings.TrimLeft(strings.TrimSpace(output), "*.")
+		t, err := time.ParseInLocation(layout, value, loc)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("cannot parse remote date %q with layout %q", output, layout)
+		}
+		return t, nil
+	}
+}
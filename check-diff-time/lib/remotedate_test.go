@@ -0,0 +1,98 @@
+package checkdifftime
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mackerelio/checkers"
+)
+
+func TestRemoteDateParsers(t *testing.T) {
+	paris, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock := layoutParser("15:04:05.000 MST Mon Jan 2 2006", time.UTC)
+	local := layoutParser("2006-01-02 15:04:05", paris)
+
+	tests := []struct {
+		name   string
+		parse  remoteDateParser
+		output string
+		want   time.Time
+		err    string
+	}{
+		{"epoch fraction", parseEpoch, "1760781600.123456789\n", time.Unix(1760781600, 123456789), ""},
+		{"epoch short fraction", parseEpoch, "1760781600.5\n", time.Unix(1760781600, 500000000), ""},
+		{"epoch long fraction truncated", parseEpoch, "1760781600.1234567899\n", time.Unix(1760781600, 123456789), ""},
+		{"epoch without fraction", parseEpoch, "1760781600\n", time.Unix(1760781600, 0), ""},
+		{"epoch literal %N", parseEpoch, "1760781600.%N\n", time.Unix(1760781600, 0), ""},
+		{"epoch garbage", parseEpoch, "sh: date: not found\n", time.Time{}, `cannot parse remote date "sh: date: not found\n" as epoch`},
+		{"epoch empty", parseEpoch, "", time.Time{}, `cannot parse remote date "" as epoch`},
+
+		{"epoch seconds", parseEpochSeconds, "1760781600\n", time.Unix(1760781600, 500000000), ""},
+		{"epoch seconds with fraction", parseEpochSeconds, "1760781600.780458992\n", time.Time{}, `cannot parse remote date "1760781600.780458992\n" as epoch seconds`},
+
+		{"rfc3339", parseRFC3339, "2026-10-18T10:15:32+02:00\n", time.Date(2026, 10, 18, 8, 15, 32, 0, time.UTC), ""},
+		{"rfc3339 nanoseconds", parseRFC3339, "2026-10-18T08:15:32.123456789Z\n", time.Date(2026, 10, 18, 8, 15, 32, 123456789, time.UTC), ""},
+		{"rfc3339 space separator", parseRFC3339, "2026-10-18 08:15:32.123456789+00:00\n", time.Date(2026, 10, 18, 8, 15, 32, 123456789, time.UTC), ""},
+		{"rfc3339 garbage", parseRFC3339, "Sun Oct 18 08:15:32 UTC 2026\n", time.Time{}, `cannot parse remote date "Sun Oct 18 08:15:32 UTC 2026\n" as RFC 3339`},
+
+		{"layout", clock, "10:15:32.123 UTC Sun Oct 18 2026\n", time.Date(2026, 10, 18, 10, 15, 32, 123000000, time.UTC), ""},
+		{"layout not synchronized", clock, "*10:15:32.123 UTC Sun Oct 18 2026\n", time.Date(2026, 10, 18, 10, 15, 32, 123000000, time.UTC), ""},
+		{"layout not authoritative", clock, ".10:15:32.123 UTC Sun Oct 18 2026\n", time.Date(2026, 10, 18, 10, 15, 32, 123000000, time.UTC), ""},
+		{"layout in timezone", local, "2026-10-18 10:15:32\n", time.Date(2026, 10, 18, 8, 15, 32, 0, time.UTC), ""},
+		{"layout mismatch", local, "18/10/2026 10:15\n", time.Time{}, `cannot parse remote date "18/10/2026 10:15\n" with layout "2006-01-02 15:04:05"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.parse(tt.output)
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("got error %v, want %q", err, tt.err)
+				}
+				if status := statusOf(err); status != checkers.UNKNOWN {
+					t.Errorf("got status %s, want UNKNOWN", status)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDefaultRemoteCommand(t *testing.T) {
+	tests := []struct {
+		format  string
+		command string
+		want    string
+		err     string
+	}{
+		{format: formatEpochFraction, want: "date +%s.%N"},
+		{format: formatEpoch, want: "date +%s"},
+		{format: formatRFC3339, want: "date --rfc-3339=ns"},
+		{format: formatLayout, err: "--remote-command is required with --remote-format layout"},
+		{format: formatLayout, command: "show clock", want: "show clock"},
+		{format: formatEpoch, command: "busybox date +%s", want: "busybox date +%s"},
+	}
+	for _, tt := range tests {
+		opts := &sshOpts{RemoteFormat: tt.format, RemoteCommand: tt.command}
+		err := opts.defaultRemoteCommand()
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%s: got error %v, want %q", tt.format, err, tt.err)
+			}
+			continue
+		}
+		if err != nil || opts.RemoteCommand != tt.want {
+			t.Errorf("%s: got %q (%v), want %q", tt.format, opts.RemoteCommand, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package checkdifftime
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mackerelio/checkers"
+	"golang.org/x/crypto/ssh"
+)
+
+// rtcCommand reads the hardware clock between two readings of the system
+// clock. since_epoch needs no privilege, hwclock is the fallback for
+// systems without the sysfs interface.
+const rtcCommand = "LC_ALL=C date +%s.%N; cat /sys/class/rtc/rtc0/since_epoch 2>/dev/null || LC_ALL=C hwclock --show 2>/dev/null; LC_ALL=C date +%s.%N"
+
+// rtcSkew is the hardware clock minus the system clock of the remote host.
+type rtcSkew struct {
+	source string
+	skew   time.Duration
+}
+
+func parseRTCOutput(output string) (*rtcSkew, error) {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	if len(lines) != 3 {
+		return nil, errors.New("cannot read the hardware clock with /sys/class/rtc/rtc0/since_epoch or hwclock")
+	}
+
+	before, err := parseEpoch(lines[0])
+	if err != nil {
+		return nil, err
+	}
+	after, err := parseEpoch(lines[2])
+	if err != nil {
+		return nil, err
+	}
+	system := midpoint(before, after)
+
+	line := strings.TrimSpace(lines[1])
+	if seconds, err := strconv.ParseInt(line, 10, 64); err == nil {
+		// since_epoch is truncated to the second
+		rtc := time.Unix(seconds, 0).Add(secondResolution)
+		return &rtcSkew{source: "since_epoch", skew: rtc.Sub(system)}, nil
+	}
+	// hwclock --show: 2026-10-18 10:02:41.123456+00:00
+	rtc, err := parseRFC3339(line)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse hardware clock %q", line)
+	}
+	return &rtcSkew{source: "hwclock", skew: rtc.Sub(system)}, nil
+}
+
+func queryRTC(d *deadline, client *ssh.Client) (*rtcSkew, error) {
+	output, err := runCommand(d, client, rtcCommand)
+	if err != nil {
+		return nil, err
+	}
+	return parseRTCOutput(output)
+}
+
+func describeRTCSkew(skew time.Duration) string {
+	switch {
+	case skew > 0:
+		return fmt.Sprintf("RTC ahead of system clock by %.3fs", skew.Seconds())
+	case skew < 0:
+		return fmt.Sprintf("RTC behind system clock by %.3fs", -skew.Seconds())
+	default:
+		return "RTC in sync with system clock"
+	}
+}
+
+func (opts *sshOpts) checkRTC(skew time.Duration) checkers.Status {
+	diff := math.Abs(skew.Seconds())
+	if diff >= opts.CriticalRTC {
+		return checkers.CRITICAL
+	}
+	if diff >= opts.WarningRTC {
+		return checkers.WARNING
+	}
+	return checkers.OK
+}
+
+func (r *rtcSkew) addTo(opts *sshOpts, m *measurement) {
+	m.add("RTC skew: %s (%s)", describeRTCSkew(r.skew), r.source)
+	if status := opts.checkRTC(r.skew); status != checkers.OK {
+		m.raise(status, fmt.Sprintf("RTC skew %+.3fs", r.skew.Seconds()))
+	}
+}
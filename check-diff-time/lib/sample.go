@@ -0,0 +1,86 @@
+package checkdifftime
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mackerelio/checkers"
+	"golang.org/x/crypto/ssh"
+)
+
+// sample is a single comparison between the local and the remote clock.
+type sample struct {
+	// local is the midpoint of the local clock around the exchange
+	local  time.Time
+	remote time.Time
+	delay  time.Duration
+}
+
+func (s sample) offset() time.Duration {
+	return s.remote.Sub(s.local)
+}
+
+// measurement is the outcome of querying one time source.
+type measurement struct {
+	sample
+	// status is the least severe status the source allows, e.g. a server
+	// reporting itself as unsynchronized cannot be OK.
+	status  checkers.Status
+	details []string
+	// alerts explains why status was raised
+	alerts []string
+}
+
+func (m *measurement) add(format string, a ...interface{}) {
+	m.details = append(m.details, fmt.Sprintf(format, a...))
+}
+
+func (m *measurement) raise(status checkers.Status, reason string) {
+	m.status = worse(m.status, status)
+	m.alerts = append(m.alerts, reason)
+}
+
+func (m *measurement) String() string {
+	return strings.Join(m.details, " - ")
+}
+
+func midpoint(before, after time.Time) time.Time {
+	return before.Add(after.Sub(before) / 2)
+}
+
+func (opts *sshOpts) sampleSSH(d *deadline, client *ssh.Client) (sample, error) {
+	d.enter(phaseSession)
+	session, err := client.NewSession()
+	if err != nil {
+		return sample{}, d.wrap(err)
+	}
+	defer session.Close()
+
+	d.enter(phaseCommand)
+	before := time.Now()
+	output, err := session.Output(opts.RemoteCommand)
+	after := time.Now()
+	if err != nil {
+		return sample{}, d.wrap(err)
+	}
+
+	remote, err := opts.parseDate(string(output))
+	if err != nil {
+		return sample{}, err
+	}
+	return sample{local: midpoint(before, after), remote: remote, delay: after.Sub(before)}, nil
+}
+
+// averageSamples combines samples into one whose offset and delay are the
+// mean of the individual ones.
+func averageSamples(samples []sample) sample {
+	var offset, delay time.Duration
+	for _, s := range samples {
+		offset += s.offset()
+		delay += s.delay
+	}
+	n := time.Duration(len(samples))
+	last := samples[len(samples)-1]
+	return sample{local: last.local, remote: last.local.Add(offset / n), delay: delay / n}
+}
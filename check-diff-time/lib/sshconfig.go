@@ -0,0 +1,96 @@
+package checkdifftime
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/kevinburke/ssh_config"
+)
+
+// hostConfig is the subset of ssh_config settings used to reach a host.
+type hostConfig struct {
+	hostname  string
+	port      int
+	user      string
+	identity  string
+	proxyJump string
+}
+
+func expandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, path[1:])
+}
+
+func loadSSHConfig(file string) (*ssh_config.Config, error) {
+	f, err := os.Open(expandHome(file))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	cfg, err := ssh_config.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", file, err)
+	}
+	return cfg, nil
+}
+
+// resolveHost looks up alias the way ssh does: the first value found for
+// each keyword wins, Host patterns and Include directives are honoured.
+func resolveHost(cfg *ssh_config.Config, alias string) (hostConfig, error) {
+	hc := hostConfig{hostname: alias}
+
+	get := func(key string) (string, error) {
+		value, err := cfg.Get(alias, key)
+		if err != nil {
+			return "", fmt.Errorf("ssh_config %s for %s: %s", key, alias, err)
+		}
+		return value, nil
+	}
+
+	hostname, err := get("HostName")
+	if err != nil {
+		return hc, err
+	}
+	if hostname != "" {
+		hc.hostname = strings.NewReplacer("%h", alias, "%%", "%").Replace(hostname)
+	}
+
+	port, err := get("Port")
+	if err != nil {
+		return hc, err
+	}
+	if port != "" {
+		hc.port, err = strconv.Atoi(port)
+		if err != nil {
+			return hc, fmt.Errorf("ssh_config Port for %s: invalid value %q", alias, port)
+		}
+	}
+
+	if hc.user, err = get("User"); err != nil {
+		return hc, err
+	}
+
+	identity, err := get("IdentityFile")
+	if err != nil {
+		return hc, err
+	}
+	hc.identity = expandHome(identity)
+
+	if hc.proxyJump, err = get("ProxyJump"); err != nil {
+		return hc, err
+	}
+	if strings.EqualFold(hc.proxyJump, "none") {
+		hc.proxyJump = ""
+	}
+	return hc, nil
+}
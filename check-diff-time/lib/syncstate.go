@@ -0,0 +1,186 @@
+package checkdifftime
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mackerelio/checkers"
+	"golang.org/x/crypto/ssh"
+)
+
+// syncStatus is the NTP synchronisation state reported by the remote host.
+type syncStatus struct {
+	tool         string
+	synchronized bool
+	stratum      int
+	server       string
+	// estimatedError is negative when the tool does not report it
+	estimatedError time.Duration
+}
+
+type syncProbe struct {
+	tool    string
+	command string
+	parse   func(output string) (*syncStatus, error)
+}
+
+// syncProbes are tried in turn until one succeeds, the most detailed first.
+var syncProbes = []syncProbe{
+	{tool: "chronyc", command: "LC_ALL=C chronyc tracking", parse: parseChronyTracking},
+	{tool: "ntpq", command: "LC_ALL=C ntpq -pn", parse: parseNtpqPeers},
+	{tool: "timedatectl", command: "LC_ALL=C timedatectl show", parse: parseTimedatectlShow},
+}
+
+func runCommand(d *deadline, client *ssh.Client, command string) (string, error) {
+	d.enter(phaseSession)
+	session, err := client.NewSession()
+	if err != nil {
+		return "", d.wrap(err)
+	}
+	defer session.Close()
+
+	d.enter(phaseCommand)
+	output, err := session.Output(command)
+	return string(output), d.wrap(err)
+}
+
+func querySyncStatus(d *deadline, client *ssh.Client) (*syncStatus, error) {
+	tools := make([]string, 0, len(syncProbes))
+	for _, probe := range syncProbes {
+		tools = append(tools, probe.tool)
+		output, err := runCommand(d, client, probe.command)
+		if d.ctx.Err() != nil {
+			return nil, err
+		}
+		if err != nil {
+			continue
+		}
+		status, err := probe.parse(output)
+		if err != nil {
+			continue
+		}
+		status.tool = probe.tool
+		return status, nil
+	}
+	return nil, fmt.Errorf("cannot read sync status with %s", strings.Join(tools, ", "))
+}
+
+func parseSeconds(value string) (time.Duration, error) {
+	fields := strings.Fields(value)
+	if len(fields) == 0 {
+		return 0, errors.New("empty value")
+	}
+	seconds, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+func parseChronyTracking(output string) (*syncStatus, error) {
+	values := map[string]string{}
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), ":")
+		if ok {
+			values[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+	}
+
+	leap, ok := values["Leap status"]
+	if !ok {
+		return nil, errors.New("no leap status in chronyc output")
+	}
+
+	status := &syncStatus{synchronized: leap != "Not synchronised", estimatedError: -1}
+	status.stratum, _ = strconv.Atoi(values["Stratum"])
+
+	// Reference ID    : C0A80101 (ntp1.example.com)
+	reference := values["Reference ID"]
+	if open := strings.Index(reference, "("); open >= 0 {
+		status.server = strings.TrimSuffix(reference[open+1:], ")")
+	} else {
+		status.server = reference
+	}
+
+	// chrony's bound on the clock error: root dispersion + root delay / 2
+	rootDelay, errDelay := parseSeconds(values["Root delay"])
+	rootDispersion, errDispersion := parseSeconds(values["Root dispersion"])
+	if errDelay == nil && errDispersion == nil {
+		status.estimatedError = rootDispersion + rootDelay/2
+	}
+	return status, nil
+}
+
+func parseNtpqPeers(output string) (*syncStatus, error) {
+	//      remote           refid      st t when poll reach   delay   offset  jitter
+	// *192.168.1.1     .GPS.            1 u   33   64  377    0.123   -0.012   0.004
+	status := &syncStatus{estimatedError: -1}
+	header := false
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.Contains(line, "remote") && strings.Contains(line, "refid") {
+			header = true
+			continue
+		}
+		if !strings.HasPrefix(line, "*") {
+			continue
+		}
+
+		fields := strings.Fields(line[1:])
+		if len(fields) < 10 {
+			continue
+		}
+		status.synchronized = true
+		status.server = fields[0]
+		if stratum, err := strconv.Atoi(fields[2]); err == nil {
+			status.stratum = stratum + 1
+		}
+		offset, errOffset := strconv.ParseFloat(fields[8], 64)
+		jitter, errJitter := strconv.ParseFloat(fields[9], 64)
+		if errOffset == nil && errJitter == nil {
+			status.estimatedError = time.Duration((math.Abs(offset) + jitter) * float64(time.Millisecond))
+		}
+	}
+	if !header {
+		return nil, errors.New("no peer list in ntpq output")
+	}
+	return status, nil
+}
+
+func parseTimedatectlShow(output string) (*syncStatus, error) {
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), "=")
+		if ok && key == "NTPSynchronized" {
+			return &syncStatus{synchronized: value == "yes", estimatedError: -1}, nil
+		}
+	}
+	return nil, errors.New("no NTPSynchronized in timedatectl output")
+}
+
+func (s *syncStatus) addTo(m *measurement) {
+	synchronized := "no"
+	if s.synchronized {
+		synchronized = "yes"
+	}
+	m.add("Synchronized: %s (%s)", synchronized, s.tool)
+	if s.stratum > 0 {
+		m.add("Stratum: %d", s.stratum)
+	}
+	if s.server != "" {
+		m.add("Source: %s", s.server)
+	}
+	if s.estimatedError >= 0 {
+		m.add("Est. error: %s", s.estimatedError)
+	}
+	if !s.synchronized {
+		m.raise(checkers.WARNING, "not synchronized")
+	}
+}
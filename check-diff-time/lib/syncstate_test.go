@@ -0,0 +1,119 @@
+package checkdifftime
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const chronySynchronized = `Reference ID    : C0A80101 (ntp1.example.com)
+Stratum         : 3
+Ref time (UTC)  : Sun Oct 18 10:01:02 2026
+System time     : 0.000012345 seconds fast of NTP time
+Last offset     : -0.000004567 seconds
+RMS offset      : 0.000023456 seconds
+Frequency       : 12.345 ppm slow
+Residual freq   : -0.001 ppm
+Skew            : 0.012 ppm
+Root delay      : 0.012000000 seconds
+Root dispersion : 0.001000000 seconds
+Update interval : 64.2 seconds
+Leap status     : Normal
+`
+
+const chronyNotSynchronised = `Reference ID    : 00000000 ()
+Stratum         : 0
+Ref time (UTC)  : Thu Jan 01 00:00:00 1970
+System time     : 0.000000000 seconds fast of NTP time
+Last offset     : +0.000000000 seconds
+RMS offset      : 0.000000000 seconds
+Frequency       : 0.000 ppm slow
+Residual freq   : +0.000 ppm
+Skew            : 0.000 ppm
+Root delay      : 1.000000000 seconds
+Root dispersion : 1.000000000 seconds
+Update interval : 0.0 seconds
+Leap status     : Not synchronised
+`
+
+const ntpqSynchronized = `     remote           refid      st t when poll reach   delay   offset  jitter
+==============================================================================
+ 0.debian.pool.n .POOL.          16 p    -   64    0    0.000   +0.000   0.000
+*192.168.1.1     .GPS.            1 u   33   64  377    0.123   -0.012   0.004
++10.0.0.2        192.168.1.1      2 u   40   64  377    0.456   +0.089   0.021
+`
+
+const ntpqNoSystemPeer = `     remote           refid      st t when poll reach   delay   offset  jitter
+==============================================================================
+ 192.168.1.1     .INIT.          16 u    -   64    0    0.000   +0.000   0.000
+ 10.0.0.2        .INIT.          16 u    -   64    0    0.000   +0.000   0.000
+`
+
+const timedatectlShow = `Timezone=Etc/UTC
+LocalRTC=no
+CanNTP=yes
+NTP=yes
+NTPSynchronized=%s
+TimeUSec=Sun 2026-10-18 10:15:32 UTC
+RTCTimeUSec=Sun 2026-10-18 10:15:32 UTC
+`
+
+func TestSyncStatusParsers(t *testing.T) {
+	tests := []struct {
+		name   string
+		parse  func(string) (*syncStatus, error)
+		output string
+		want   *syncStatus
+	}{
+		{
+			name: "chrony synchronized", parse: parseChronyTracking, output: chronySynchronized,
+			want: &syncStatus{synchronized: true, stratum: 3, server: "ntp1.example.com", estimatedError: 7 * time.Millisecond},
+		},
+		{
+			name: "chrony not synchronised", parse: parseChronyTracking, output: chronyNotSynchronised,
+			want: &syncStatus{synchronized: false, estimatedError: 1500 * time.Millisecond},
+		},
+		{name: "chrony not running", parse: parseChronyTracking, output: "506 Cannot talk to daemon\n"},
+		{
+			name: "ntpq synchronized", parse: parseNtpqPeers, output: ntpqSynchronized,
+			want: &syncStatus{synchronized: true, stratum: 2, server: "192.168.1.1", estimatedError: 16 * time.Microsecond},
+		},
+		{
+			name: "ntpq without system peer", parse: parseNtpqPeers, output: ntpqNoSystemPeer,
+			want: &syncStatus{synchronized: false, estimatedError: -1},
+		},
+		{name: "ntpq not running", parse: parseNtpqPeers, output: "ntpq: read: Connection refused\n"},
+		{
+			name: "timedatectl synchronized", parse: parseTimedatectlShow, output: strings.Replace(timedatectlShow, "%s", "yes", 1),
+			want: &syncStatus{synchronized: true, estimatedError: -1},
+		},
+		{
+			name: "timedatectl not synchronized", parse: parseTimedatectlShow, output: strings.Replace(timedatectlShow, "%s", "no", 1),
+			want: &syncStatus{synchronized: false, estimatedError: -1},
+		},
+		{name: "timedatectl without show", parse: parseTimedatectlShow, output: "Unknown operation show\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.parse(tt.output)
+			if tt.want == nil {
+				if err == nil {
+					t.Fatalf("got %+v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			// float parsing may be off by a nanosecond
+			if diff := got.estimatedError - tt.want.estimatedError; diff >= -time.Nanosecond && diff <= time.Nanosecond {
+				got.estimatedError = tt.want.estimatedError
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,208 @@
+package checkdifftime
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"golang.org/x/crypto/cryptobyte"
+)
+
+const (
+	tlsRecordHandshake = 22
+	tlsRecordAlert     = 21
+	tlsClientHello     = 1
+	tlsServerHello     = 2
+	tlsVersion12       = 0x0303
+
+	// tlsPlausibleTime bounds how far gmt_unix_time may be from the local
+	// clock before it is taken for random bytes.
+	tlsPlausibleTime = 365 * 24 * time.Hour
+)
+
+// tlsCipherSuites are the usual TLS 1.2 suites, offered so that any server
+// answers; the handshake is dropped after the ServerHello.
+var tlsCipherSuites = []uint16{
+	0xc02b, 0xc02f, 0xc02c, 0xc030, 0xcca9, 0xcca8, // ECDHE AEAD
+	0xc009, 0xc013, 0xc00a, 0xc014, // ECDHE CBC
+	0x009c, 0x009d, 0x002f, 0x0035, // RSA
+}
+
+// tlsClientHelloRecord builds a TLS 1.2 ClientHello. It only offers TLS 1.2
+// since TLS 1.3 removed the time from the server random.
+func tlsClientHelloRecord(serverName string, random []byte) []byte {
+	var b cryptobyte.Builder
+	b.AddUint8(tlsRecordHandshake)
+	b.AddUint16(0x0301)
+	b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+		b.AddUint8(tlsClientHello)
+		b.AddUint24LengthPrefixed(func(b *cryptobyte.Builder) {
+			b.AddUint16(tlsVersion12)
+			b.AddBytes(random)
+			b.AddUint8(0) // no session id
+			b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+				for _, suite := range tlsCipherSuites {
+					b.AddUint16(suite)
+				}
+			})
+			b.AddUint8LengthPrefixed(func(b *cryptobyte.Builder) {
+				b.AddUint8(0) // null compression
+			})
+			b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+				if serverName != "" {
+					b.AddUint16(0) // server_name
+					b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+						b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+							b.AddUint8(0) // host_name
+							b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+								b.AddBytes([]byte(serverName))
+							})
+						})
+					})
+				}
+				b.AddUint16(10) // supported_groups: x25519, P-256, P-384
+				b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+					b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+						b.AddUint16(0x001d)
+						b.AddUint16(0x0017)
+						b.AddUint16(0x0018)
+					})
+				})
+				b.AddUint16(11) // ec_point_formats: uncompressed
+				b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+					b.AddUint8LengthPrefixed(func(b *cryptobyte.Builder) {
+						b.AddUint8(0)
+					})
+				})
+				b.AddUint16(13) // signature_algorithms
+				b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+					b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+						for _, alg := range []uint16{0x0403, 0x0503, 0x0603, 0x0804, 0x0805, 0x0806, 0x0401, 0x0501, 0x0601, 0x0201} {
+							b.AddUint16(alg)
+						}
+					})
+				})
+				b.AddUint16(0xff01) // renegotiation_info, empty
+				b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+					b.AddUint8(0)
+				})
+			})
+		})
+	})
+	return b.BytesOrPanic()
+}
+
+// parseServerHello returns the gmt_unix_time of the first record the
+// server sends in reply to a ClientHello.
+func parseServerHello(r io.Reader) (time.Time, error) {
+	header := make([]byte, 5)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return time.Time{}, err
+	}
+	body := make([]byte, binary.BigEndian.Uint16(header[3:]))
+	if _, err := io.ReadFull(r, body); err != nil {
+		return time.Time{}, err
+	}
+
+	switch header[0] {
+	case tlsRecordHandshake:
+	case tlsRecordAlert:
+		if len(body) == 2 {
+			return time.Time{}, fmt.Errorf("handshake rejected with TLS alert %d", body[1])
+		}
+		return time.Time{}, errors.New("handshake rejected with a TLS alert")
+	default:
+		return time.Time{}, fmt.Errorf("unexpected TLS record type %d", header[0])
+	}
+
+	// handshake type (1), length (3), version (2), random (32)
+	if len(body) < 38 || body[0] != tlsServerHello {
+		return time.Time{}, errors.New("no ServerHello in the server response")
+	}
+	return time.Unix(int64(binary.BigEndian.Uint32(body[6:10])), 0), nil
+}
+
+// sampleTLS starts a TLS 1.2 handshake and reads the time the server put in
+// the first four bytes of its random (gmt_unix_time). Only a few stacks
+// still do, the others send random bytes which are detected by being
+// implausibly far from the local clock.
+func (opts *sshOpts) sampleTLS(d *deadline) (sample, error) {
+	d.enter(phaseConnect)
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(d.ctx, "tcp", opts.addr())
+	if err != nil {
+		return sample{}, d.wrap(unreachable(err))
+	}
+	defer conn.Close()
+	d.watch(conn)
+
+	serverName := opts.host
+	if net.ParseIP(serverName) != nil {
+		serverName = ""
+	}
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		return sample{}, err
+	}
+
+	d.enter(phaseHandshake)
+	before := time.Now()
+	if _, err := conn.Write(tlsClientHelloRecord(serverName, random)); err != nil {
+		return sample{}, d.wrap(unreachable(err))
+	}
+	remote, err := parseServerHello(conn)
+	after := time.Now()
+	if err != nil {
+		return sample{}, d.wrap(fmt.Errorf("%s: %s", opts.addr(), err))
+	}
+
+	local := midpoint(before, after)
+	if diff := remote.Sub(local); diff > tlsPlausibleTime || diff < -tlsPlausibleTime {
+		return sample{}, opts.noTLSTime()
+	}
+	return sample{local: local, remote: remote.Add(secondResolution), delay: after.Sub(before)}, nil
+}
+
+func (opts *sshOpts) noTLSTime() error {
+	return fmt.Errorf("%s does not provide its time in the TLS handshake", opts.addr())
+}
+
+// measureTLS takes at least two samples: random bytes pass the
+// plausibility window of sampleTLS too often, but two of them hardly ever
+// move along with the local clock.
+func (opts *sshOpts) measureTLS(d *deadline) (*measurement, error) {
+	count := opts.Samples
+	if count < 2 {
+		count = 2
+	}
+	samples := make([]sample, 0, count)
+	for i := 0; i < count; i++ {
+		s, err := opts.sampleTLS(d)
+		if err != nil {
+			return nil, err
+		}
+		if i > 0 {
+			previous := samples[i-1]
+			elapsed := s.local.Sub(previous.local)
+			if diff := s.remote.Sub(previous.remote); diff > elapsed+time.Second || diff < -elapsed-time.Second {
+				return nil, opts.noTLSTime()
+			}
+		}
+		samples = append(samples, s)
+	}
+	result := averageSamples(samples)
+	if err := opts.rebase(&result); err != nil {
+		return nil, err
+	}
+
+	m := &measurement{sample: result}
+	m.add("%s: %s", opts.baselineLabel(), formatDate(result.local))
+	m.add("Server date: %s", formatDate(result.remote))
+	m.add("Diff time: %s", describeOffset(result.offset()))
+	m.add("Round trip: %s", result.delay)
+	return m, nil
+}
@@ -0,0 +1,134 @@
+package checkdifftime
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// serverHelloRecord is the start of a TLS 1.2 ServerHello whose random
+// begins with gmtUnixTime.
+func serverHelloRecord(gmtUnixTime uint32) []byte {
+	body := make([]byte, 38)
+	body[0] = tlsServerHello
+	body[3] = 34
+	binary.BigEndian.PutUint16(body[4:], tlsVersion12)
+	binary.BigEndian.PutUint32(body[6:], gmtUnixTime)
+	record := []byte{tlsRecordHandshake, 0x03, 0x03, 0, byte(len(body))}
+	return append(record, body...)
+}
+
+func TestParseServerHello(t *testing.T) {
+	tests := []struct {
+		name   string
+		record []byte
+		want   time.Time
+		err    string
+	}{
+		{name: "server hello", record: serverHelloRecord(1760781600), want: time.Unix(1760781600, 0)},
+		{name: "alert", record: []byte{tlsRecordAlert, 0x03, 0x03, 0, 2, 2, 70}, err: "handshake rejected with TLS alert 70"},
+		{name: "other record", record: []byte{23, 0x03, 0x03, 0, 1, 0}, err: "unexpected TLS record type 23"},
+		{name: "not a server hello", record: []byte{tlsRecordHandshake, 0x03, 0x03, 0, 4, 14, 0, 0, 0}, err: "no ServerHello"},
+		{name: "truncated", record: serverHelloRecord(1760781600)[:20], err: io.ErrUnexpectedEOF.Error()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseServerHello(bytes.NewReader(tt.record))
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("got error %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+// tlsServer answers every ClientHello with a ServerHello carrying the time
+// returned by gmtUnixTime.
+func tlsServer(t *testing.T, gmtUnixTime func() uint32) *sshOpts {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			header := make([]byte, 5)
+			if _, err := io.ReadFull(conn, header); err == nil {
+				io.CopyN(io.Discard, conn, int64(binary.BigEndian.Uint16(header[3:])))
+				conn.Write(serverHelloRecord(gmtUnixTime()))
+			}
+			conn.Close()
+		}
+	}()
+
+	opts := &sshOpts{Mode: "tls", Timeout: 2, Samples: 1}
+	opts.Port = listener.Addr().(*net.TCPAddr).Port
+	return opts.target("127.0.0.1")
+}
+
+func TestMeasureTLS(t *testing.T) {
+	skew := 90 * time.Second
+	opts := tlsServer(t, func() uint32 { return uint32(time.Now().Add(skew).Unix()) })
+	d, cancel := opts.newDeadline()
+	defer cancel()
+	m, err := opts.measureTLS(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := m.offset() - skew; diff < -time.Second || diff > time.Second {
+		t.Errorf("got offset %s, want %s", m.offset(), skew)
+	}
+}
+
+func TestMeasureTLSImplausibleTime(t *testing.T) {
+	// random bytes in place of the time
+	opts := tlsServer(t, func() uint32 { return 0x1234abcd })
+	d, cancel := opts.newDeadline()
+	defer cancel()
+	_, err := opts.measureTLS(d)
+	want := "127.0.0.1:" + strconv.Itoa(opts.Port) + " does not provide its time in the TLS handshake"
+	if err == nil || err.Error() != want {
+		t.Fatalf("got error %v, want %q", err, want)
+	}
+}
+
+func TestMeasureTLSRandomTime(t *testing.T) {
+	// about 1.5% of random values fall within the plausibility window
+	opts := tlsServer(t, func() uint32 {
+		b := make([]byte, 4)
+		rand.Read(b)
+		return binary.BigEndian.Uint32(b)
+	})
+	for i := 0; i < 500; i++ {
+		d, cancel := opts.newDeadline()
+		m, err := opts.measureTLS(d)
+		cancel()
+		if err == nil {
+			t.Fatalf("random gmt_unix_time accepted with offset %s", m.offset())
+		}
+		if !strings.Contains(err.Error(), "does not provide its time in the TLS handshake") {
+			t.Fatal(err)
+		}
+	}
+}
@@ -3,8 +3,9 @@ module github.com/jbox-web/go-check-plugins/check-file-age
 go 1.20
 
 require (
+	github.com/dustin/go-humanize v1.0.1
 	github.com/jessevdk/go-flags v1.5.0
 	github.com/mackerelio/checkers v0.0.4
 )
 
-require golang.org/x/sys v0.0.0-20210320140829-1e4c9ba3b0c4 // indirect
+require golang.org/x/sys v0.0.0-20210320140829-1e4c9ba3b0c4
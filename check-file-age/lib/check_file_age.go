@@ -1,11 +1,12 @@
 package checkfileage
 
 import (
+	"errors"
 	"fmt"
 	"math"
 	"os"
+	"regexp"
 	"strconv"
-	"strings"
 	"time"
 
 	"github.com/jessevdk/go-flags"
@@ -19,40 +20,33 @@ func Do() {
 	ckr.Exit()
 }
 
-type monitor struct {
-	warningAge   int64
-	warningSize  int64
-	criticalAge  int64
-	criticalSize int64
+// limits is the range of acceptable values, a zero bound is not checked.
+type limits struct {
+	min int64
+	max int64
 }
 
-func (m monitor) hasWarningAge() bool {
-	return m.warningAge != 0
+func (l limits) violated(value int64) bool {
+	return (l.min != 0 && value < l.min) || (l.max != 0 && value > l.max)
 }
 
-func (m monitor) hasWarningSize() bool {
-	return m.warningSize != 0
+type monitor struct {
+	warningAge   limits
+	warningSize  limits
+	criticalAge  limits
+	criticalSize limits
+	content      *contentRule
 }
 
 func (m monitor) CheckWarning(age, size int64) bool {
-	return (m.hasWarningAge() && m.warningAge < age) ||
-		(m.hasWarningSize() && m.warningSize > size)
-}
-
-func (m monitor) hasCriticalAge() bool {
-	return m.criticalAge != 0
-}
-
-func (m monitor) hasCriticalSize() bool {
-	return m.criticalSize != 0
+	return m.warningAge.violated(age) || m.warningSize.violated(size)
 }
 
 func (m monitor) CheckCritical(age, size int64) bool {
-	return (m.hasCriticalAge() && m.criticalAge < age) ||
-		(m.hasCriticalSize() && m.criticalSize > size)
+	return m.criticalAge.violated(age) || m.criticalSize.violated(size)
 }
 
-func newMonitor(warningAge, warningSize, criticalAge, criticalSize int64) *monitor {
+func newMonitor(warningAge, warningSize, criticalAge, criticalSize limits) *monitor {
 	return &monitor{
 		warningAge:   warningAge,
 		warningSize:  warningSize,
@@ -104,12 +98,42 @@ func secondsToHuman(input int64) (result string) {
 }
 
 var opts struct {
-	File          string `short:"f" long:"file" required:"true" description:"monitor file name"`
-	WarningAge    int64  `short:"w" long:"warning-age" default:"240" description:"warning if more old than"`
-	WarningSize   int64  `short:"W" long:"warning-size" description:"warning if file size less than"`
-	CriticalAge   int64  `short:"c" long:"critical-age" default:"600" description:"critical if more old than"`
-	CriticalSize  int64  `short:"C" long:"critical-size" default:"0" description:"critical if file size less than"`
-	IgnoreMissing bool   `short:"i" long:"ignore-missing" description:"skip alert if file doesn't exist"`
+	File            string   `short:"f" long:"file" required:"true" description:"monitor file name, directory or glob pattern"`
+	Select          string   `short:"s" long:"select" default:"newest" choice:"newest" choice:"oldest" choice:"all" description:"file checked when several match"`
+	TimeField       string   `short:"t" long:"time-field" default:"mtime" choice:"mtime" choice:"ctime" choice:"atime" choice:"btime" description:"file time the age is computed from (ctime, atime and btime on Linux only)"`
+	WarningAge      ageFlag  `short:"w" long:"warning-age" default:"240" description:"warning if more old than (seconds or duration like 90m, 36h, 7d)"`
+	WarningSize     sizeFlag `short:"W" long:"warning-size" description:"warning if file size less than (bytes or size like 10MB, 1.5GiB)"`
+	CriticalAge     ageFlag  `short:"c" long:"critical-age" default:"600" description:"critical if more old than (seconds or duration like 90m, 36h, 7d)"`
+	CriticalSize    sizeFlag `short:"C" long:"critical-size" default:"0" description:"critical if file size less than (bytes or size like 10MB, 1.5GiB)"`
+	WarningMinAge   ageFlag  `long:"warning-min-age" description:"warning if more recent than (seconds or duration like 90m, 36h, 7d)"`
+	CriticalMinAge  ageFlag  `long:"critical-min-age" description:"critical if more recent than (seconds or duration like 90m, 36h, 7d)"`
+	WarningMaxSize  sizeFlag `long:"warning-max-size" description:"warning if file size more than (bytes or size like 10MB, 1.5GiB)"`
+	CriticalMaxSize sizeFlag `long:"critical-max-size" description:"critical if file size more than (bytes or size like 10MB, 1.5GiB)"`
+	IgnoreMissing   bool     `short:"i" long:"ignore-missing" description:"skip alert if file doesn't exist"`
+	Recursive       bool     `short:"r" long:"recursive" description:"look for files in subdirectories too"`
+	MaxDepth        int      `long:"max-depth" description:"directory levels looked into with --recursive (0: no limit)"`
+	Include         []string `long:"include" description:"only consider files whose name matches this pattern, can be repeated"`
+	Exclude         []string `long:"exclude" description:"ignore files and directories whose name matches this pattern, can be repeated"`
+	CountOlderThan  ageFlag  `long:"count-older-than" description:"count files more old than (seconds or duration like 90m, 36h, 7d)"`
+	CountLargerThan sizeFlag `long:"count-larger-than" description:"count files bigger than (bytes or size like 10MB, 1.5GiB)"`
+	WarningCount    *int     `long:"warning-count" description:"warning if more files counted than, enables count mode"`
+	CriticalCount   *int     `long:"critical-count" description:"critical if more files counted than, enables count mode"`
+	Match           []string `long:"match" description:"regexp the content must match, can be repeated"`
+	NotMatch        []string `long:"not-match" description:"regexp the content must not match, can be repeated"`
+	TailLines       int      `long:"tail-lines" description:"only match the last lines of the file (0: whole file)"`
+	MaxBytes        sizeFlag `long:"max-bytes" default:"1MiB" description:"bytes read from the end of the file for --match and --not-match"`
+	MatchState      string   `long:"match-state" default:"critical" choice:"warning" choice:"critical" choice:"unknown" description:"status when the content assertions fail"`
+	TimeJSONKey     string   `long:"time-json-key" description:"take the time from this key of the JSON content, e.g. backup.finished_at"`
+	TimeRegex       string   `long:"time-regex" description:"take the time from the first capture group of this regexp on the content"`
+	TimeContent     bool     `long:"time-content" description:"take the time from the whole content"`
+	TimeFormat      string   `long:"time-format" default:"auto" description:"format of the time in the content: epoch, rfc3339, auto (either) or a Go time layout"`
+	TimeZone        string   `long:"time-zone" default:"Local" description:"time zone of the time in the content when the layout has none"`
+	LogCritical     []string `long:"log-critical" description:"regexp of new log lines counted as critical, can be repeated, enables log mode"`
+	LogWarning      []string `long:"log-warning" description:"regexp of new log lines counted as warning, can be repeated, enables log mode"`
+	StateFile       string   `long:"state-file" description:"file keeping the position reached in the log between runs (log mode)"`
+	CriticalLines   int      `long:"critical-lines" description:"critical if more new lines match --log-critical than"`
+	WarningLines    int      `long:"warning-lines" description:"warning if more new lines match --log-warning than"`
+	MaxShownLines   int      `long:"max-shown-lines" default:"10" description:"matching lines shown in the output"`
 }
 
 func run(args []string) *checkers.Checker {
@@ -118,7 +142,13 @@ func run(args []string) *checkers.Checker {
 		os.Exit(1)
 	}
 
-	stat, err := os.Stat(opts.File)
+	w := walker{recursive: opts.Recursive, maxDepth: opts.MaxDepth, include: opts.Include, exclude: opts.Exclude}
+	paths, err := w.findFiles(opts.File)
+	counting := opts.WarningCount != nil || opts.CriticalCount != nil
+	// an empty directory is what count mode hopes for
+	if err == nil && len(paths) == 0 && !counting {
+		err = fmt.Errorf("no file matches %s", opts.File)
+	}
 	if err != nil {
 		if opts.IgnoreMissing {
 			return checkers.Ok("No such file, but ignore missing is set.")
@@ -126,23 +156,163 @@ func run(args []string) *checkers.Checker {
 		return checkers.Unknown(err.Error())
 	}
 
-	monitor := newMonitor(opts.WarningAge, opts.WarningSize, opts.CriticalAge, opts.CriticalSize)
+	if len(opts.LogCritical) > 0 || len(opts.LogWarning) > 0 {
+		return checkLog(paths)
+	}
 
-	result := checkers.OK
+	timeOf, err := newTimeSource()
+	if err != nil {
+		return checkers.Unknown(err.Error())
+	}
+	files, err := statFiles(paths, time.Now(), timeOf)
+	if err != nil {
+		return checkers.Unknown(err.Error())
+	}
+	if len(files) == 0 && !counting {
+		return checkers.Unknown(fmt.Sprintf("no file matches %s", opts.File))
+	}
+
+	if counting {
+		c := counter{
+			olderThan:  int64(opts.CountOlderThan),
+			largerThan: int64(opts.CountLargerThan),
+			warning:    opts.WarningCount,
+			critical:   opts.CriticalCount,
+		}
+		return c.check(selectFiles(files, "all"), opts.File)
+	}
+	files = selectFiles(files, opts.Select)
 
-	mtime := stat.ModTime()
-	age := time.Now().Unix() - mtime.Unix()
-	size := stat.Size()
+	monitor := newMonitor(
+		limits{min: int64(opts.WarningMinAge), max: int64(opts.WarningAge)},
+		limits{min: int64(opts.WarningSize), max: int64(opts.WarningMaxSize)},
+		limits{min: int64(opts.CriticalMinAge), max: int64(opts.CriticalAge)},
+		limits{min: int64(opts.CriticalSize), max: int64(opts.CriticalMaxSize)},
+	)
+	if len(opts.Match) > 0 || len(opts.NotMatch) > 0 {
+		monitor.content, err = newContentRule()
+		if err != nil {
+			return checkers.Unknown(err.Error())
+		}
+	}
 
-	if monitor.CheckWarning(age, size) {
-		result = checkers.WARNING
+	if opts.Select == "all" {
+		return checkFiles(monitor, files)
+	}
+	return checkers.NewChecker(monitor.evaluate(files[0]))
+}
+
+// newTimeSource returns where the age is read from: the file time given
+// by --time-field or a time written in the content.
+func newTimeSource() (timeSource, error) {
+	sources := 0
+	for _, set := range []bool{opts.TimeJSONKey != "", opts.TimeRegex != "", opts.TimeContent} {
+		if set {
+			sources++
+		}
+	}
+	if sources == 0 {
+		return fieldTime(opts.TimeField), nil
+	}
+	if sources > 1 {
+		return nil, errors.New("--time-json-key, --time-regex and --time-content are mutually exclusive")
 	}
 
-	if monitor.CheckCritical(age, size) {
+	location, err := time.LoadLocation(opts.TimeZone)
+	if err != nil {
+		return nil, err
+	}
+	c := contentTime{jsonKey: opts.TimeJSONKey, format: opts.TimeFormat, location: location, maxBytes: int64(opts.MaxBytes)}
+	if opts.TimeRegex != "" {
+		if c.regex, err = regexp.Compile(opts.TimeRegex); err != nil {
+			return nil, err
+		}
+	}
+	return c.source(), nil
+}
+
+// checkLog scans the lines appended to the newest file since the previous
+// run for the --log-critical and --log-warning regexps.
+func checkLog(paths []string) *checkers.Checker {
+	if opts.StateFile == "" {
+		return checkers.Unknown("--state-file is required with --log-critical and --log-warning")
+	}
+	critical, err := compilePatterns(opts.LogCritical)
+	if err != nil {
+		return checkers.Unknown(err.Error())
+	}
+	warning, err := compilePatterns(opts.LogWarning)
+	if err != nil {
+		return checkers.Unknown(err.Error())
+	}
+
+	files, err := statFiles(paths, time.Now(), fieldTime("mtime"))
+	if err != nil {
+		return checkers.Unknown(err.Error())
+	}
+	if len(files) == 0 {
+		return checkers.Unknown(fmt.Sprintf("no file matches %s", opts.File))
+	}
+	path := selectFiles(files, "newest")[0].path
+	stat, err := os.Stat(path)
+	if err != nil {
+		return checkers.Unknown(err.Error())
+	}
+
+	state, err := loadLogState(opts.StateFile)
+	if err != nil {
+		return checkers.Unknown(fmt.Sprintf("cannot read state file: %s", err))
+	}
+	scanner := &logScanner{critical: critical, warning: warning, maxShown: opts.MaxShownLines}
+	note, err := tailLog(path, stat, state, scanner)
+	if err != nil {
+		return checkers.Unknown(err.Error())
+	}
+	if err := state.save(opts.StateFile); err != nil {
+		return checkers.Unknown(fmt.Sprintf("cannot save state file: %s", err))
+	}
+
+	result := checkers.OK
+	if scanner.warningCount > opts.WarningLines {
+		result = checkers.WARNING
+	}
+	if scanner.criticalCount > opts.CriticalLines {
 		result = checkers.CRITICAL
 	}
 
-	duration := strings.TrimSpace(secondsToHuman(age))
-	msg := fmt.Sprintf("%s is %d seconds old (%s) and %d bytes.", opts.File, age, duration, size)
+	msg := fmt.Sprintf("%s: %d new lines, %d critical and %d warning matches", path, scanner.lines, scanner.criticalCount, scanner.warningCount)
+	if note != "" {
+		msg += " (" + note + ")"
+	}
+	for _, line := range scanner.shown {
+		msg += "\n" + line
+	}
+	if hidden := scanner.criticalCount + scanner.warningCount - len(scanner.shown); hidden > 0 {
+		msg += fmt.Sprintf("\n... and %d more", hidden)
+	}
 	return checkers.NewChecker(result, msg)
 }
+
+var matchStates = map[string]checkers.Status{
+	"warning":  checkers.WARNING,
+	"critical": checkers.CRITICAL,
+	"unknown":  checkers.UNKNOWN,
+}
+
+func newContentRule() (*contentRule, error) {
+	match, err := compilePatterns(opts.Match)
+	if err != nil {
+		return nil, err
+	}
+	notMatch, err := compilePatterns(opts.NotMatch)
+	if err != nil {
+		return nil, err
+	}
+	return &contentRule{
+		match:    match,
+		notMatch: notMatch,
+		lines:    opts.TailLines,
+		maxBytes: int64(opts.MaxBytes),
+		status:   matchStates[opts.MatchState],
+	}, nil
+}
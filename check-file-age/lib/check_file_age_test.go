@@ -0,0 +1,75 @@
+package checkfileage
+
+import (
+	"testing"
+
+	"github.com/mackerelio/checkers"
+)
+
+func TestLimitsViolated(t *testing.T) {
+	tests := []struct {
+		limits limits
+		value  int64
+		want   bool
+	}{
+		{limits{}, 0, false},
+		{limits{}, 1 << 40, false},
+		{limits{min: 10}, 9, true},
+		{limits{min: 10}, 10, false},
+		{limits{max: 10}, 10, false},
+		{limits{max: 10}, 11, true},
+		{limits{min: 10, max: 20}, 9, true},
+		{limits{min: 10, max: 20}, 15, false},
+		{limits{min: 10, max: 20}, 21, true},
+	}
+	for _, tt := range tests {
+		if got := tt.limits.violated(tt.value); got != tt.want {
+			t.Errorf("%+v.violated(%d) = %v, want %v", tt.limits, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestMonitorCheck(t *testing.T) {
+	bounds := limits{min: 100, max: 200}
+	tests := []struct {
+		name    string
+		monitor *monitor
+		age     int64
+		size    int64
+		want    checkers.Status
+	}{
+		{"no limits", newMonitor(limits{}, limits{}, limits{}, limits{}), 1000, 1000, checkers.OK},
+
+		{"warning age in range", newMonitor(bounds, limits{}, limits{}, limits{}), 150, 0, checkers.OK},
+		{"warning age below min", newMonitor(bounds, limits{}, limits{}, limits{}), 99, 0, checkers.WARNING},
+		{"warning age above max", newMonitor(bounds, limits{}, limits{}, limits{}), 201, 0, checkers.WARNING},
+		{"warning size in range", newMonitor(limits{}, bounds, limits{}, limits{}), 0, 150, checkers.OK},
+		{"warning size below min", newMonitor(limits{}, bounds, limits{}, limits{}), 0, 99, checkers.WARNING},
+		{"warning size above max", newMonitor(limits{}, bounds, limits{}, limits{}), 0, 201, checkers.WARNING},
+
+		{"critical age in range", newMonitor(limits{}, limits{}, bounds, limits{}), 150, 0, checkers.OK},
+		{"critical age below min", newMonitor(limits{}, limits{}, bounds, limits{}), 99, 0, checkers.CRITICAL},
+		{"critical age above max", newMonitor(limits{}, limits{}, bounds, limits{}), 201, 0, checkers.CRITICAL},
+		{"critical size in range", newMonitor(limits{}, limits{}, limits{}, bounds), 0, 150, checkers.OK},
+		{"critical size below min", newMonitor(limits{}, limits{}, limits{}, bounds), 0, 99, checkers.CRITICAL},
+		{"critical size above max", newMonitor(limits{}, limits{}, limits{}, bounds), 0, 201, checkers.CRITICAL},
+
+		// the usual setup: -w 240 -c 600 -W 10 -C 1 --warning-max-size 1000 --critical-max-size 5000
+		{"fresh and sized", newMonitor(limits{max: 240}, limits{min: 10, max: 1000}, limits{max: 600}, limits{min: 1, max: 5000}), 60, 500, checkers.OK},
+		{"old age warns", newMonitor(limits{max: 240}, limits{min: 10, max: 1000}, limits{max: 600}, limits{min: 1, max: 5000}), 300, 500, checkers.WARNING},
+		{"very old is critical", newMonitor(limits{max: 240}, limits{min: 10, max: 1000}, limits{max: 600}, limits{min: 1, max: 5000}), 700, 500, checkers.CRITICAL},
+		{"small warns", newMonitor(limits{max: 240}, limits{min: 10, max: 1000}, limits{max: 600}, limits{min: 1, max: 5000}), 60, 5, checkers.WARNING},
+		{"empty is critical", newMonitor(limits{max: 240}, limits{min: 10, max: 1000}, limits{max: 600}, limits{min: 1, max: 5000}), 60, 0, checkers.CRITICAL},
+		{"large warns", newMonitor(limits{max: 240}, limits{min: 10, max: 1000}, limits{max: 600}, limits{min: 1, max: 5000}), 60, 2000, checkers.WARNING},
+		{"huge is critical", newMonitor(limits{max: 240}, limits{min: 10, max: 1000}, limits{max: 600}, limits{min: 1, max: 5000}), 60, 6000, checkers.CRITICAL},
+		{"critical wins over warning", newMonitor(limits{max: 240}, limits{min: 10, max: 1000}, limits{max: 600}, limits{min: 1, max: 5000}), 300, 6000, checkers.CRITICAL},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.monitor.check(fileInfo{age: tt.age, size: tt.size}); got != tt.want {
+				t.Errorf("check(age %d, size %d) = %s, want %s", tt.age, tt.size, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package checkfileage
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/mackerelio/checkers"
+)
+
+const tailChunkSize = 4096
+
+// contentRule asserts on the content of a file, or on its last lines.
+type contentRule struct {
+	match    []*regexp.Regexp
+	notMatch []*regexp.Regexp
+	lines    int
+	maxBytes int64
+	// status is the result when an assertion fails
+	status checkers.Status
+}
+
+func compilePatterns(patterns []string) ([]*regexp.Regexp, error) {
+	regexps := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, err
+		}
+		regexps = append(regexps, re)
+	}
+	return regexps, nil
+}
+
+// readTail returns the last lines of a file, or all of it when lines is 0,
+// reading at most maxBytes from its end.
+func readTail(path string, lines int, maxBytes int64) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	stat, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+	start := int64(0)
+	if maxBytes > 0 && stat.Size() > maxBytes {
+		start = stat.Size() - maxBytes
+	}
+
+	if lines == 0 {
+		data, err := io.ReadAll(io.NewSectionReader(f, start, stat.Size()-start))
+		return string(data), err
+	}
+
+	// read backwards until the chunks hold enough lines
+	var data []byte
+	for pos := stat.Size(); pos > start; {
+		size := int64(tailChunkSize)
+		if pos-start < size {
+			size = pos - start
+		}
+		pos -= size
+		chunk := make([]byte, size)
+		if _, err := f.ReadAt(chunk, pos); err != nil && err != io.EOF {
+			return "", err
+		}
+		data = append(chunk, data...)
+		if bytes.Count(bytes.TrimSuffix(data, []byte("\n")), []byte("\n")) >= lines {
+			break
+		}
+	}
+
+	tail := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	if len(tail) > lines {
+		tail = tail[len(tail)-lines:]
+	}
+	return strings.Join(tail, "\n"), nil
+}
+
+func (c contentRule) describe() string {
+	switch c.lines {
+	case 0:
+		return "content"
+	case 1:
+		return "last line"
+	}
+	return fmt.Sprintf("last %d lines", c.lines)
+}
+
+// check returns the status of the content of path and why it failed.
+func (c contentRule) check(path string) (checkers.Status, string) {
+	text, err := readTail(path, c.lines, c.maxBytes)
+	if err != nil {
+		return checkers.UNKNOWN, fmt.Sprintf("(cannot read content: %s)", err)
+	}
+
+	what := c.describe()
+	for _, re := range c.match {
+		if !re.MatchString(text) {
+			return c.status, fmt.Sprintf("(%s: no match for %q)", what, re)
+		}
+	}
+	for _, re := range c.notMatch {
+		if re.MatchString(text) {
+			return c.status, fmt.Sprintf("(%s: unexpected match for %q)", what, re)
+		}
+	}
+	return checkers.OK, ""
+}
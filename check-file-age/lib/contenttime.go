@@ -0,0 +1,167 @@
+package checkfileage
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	epochPattern    = regexp.MustCompile(`^-?\d+(\.\d+)?$`)
+	keyIndexPattern = regexp.MustCompile(`^([^\[]*)((?:\[\d+\])*)$`)
+)
+
+// readHead reads a whole file, failing when it is larger than maxBytes.
+func readHead(path string, maxBytes int64) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(io.LimitReader(f, maxBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > maxBytes {
+		return nil, fmt.Errorf("%s is larger than --max-bytes", path)
+	}
+	return data, nil
+}
+
+// lookupKey follows a key such as finished_at, backup.finished_at or
+// runs[0].end through decoded JSON. A leading $ is allowed.
+func lookupKey(doc interface{}, key string) (interface{}, error) {
+	key = strings.TrimPrefix(strings.TrimPrefix(key, "$"), ".")
+	value := doc
+	for _, part := range strings.Split(key, ".") {
+		match := keyIndexPattern.FindStringSubmatch(part)
+		if match == nil {
+			return nil, fmt.Errorf("invalid key %q", key)
+		}
+		if name := match[1]; name != "" {
+			object, ok := value.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("no key %q", key)
+			}
+			if value, ok = object[name]; !ok {
+				return nil, fmt.Errorf("no key %q", key)
+			}
+		}
+		for _, index := range strings.FieldsFunc(match[2], func(r rune) bool { return r == '[' || r == ']' }) {
+			i, _ := strconv.Atoi(index)
+			array, ok := value.([]interface{})
+			if !ok || i >= len(array) {
+				return nil, fmt.Errorf("no key %q", key)
+			}
+			value = array[i]
+		}
+	}
+	return value, nil
+}
+
+// parseTime parses value according to format: epoch, rfc3339, auto (either
+// of them) or a Go time layout, in loc when the layout has no zone.
+func parseTime(value, format string, loc *time.Location) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if format == "auto" {
+		format = "rfc3339"
+		if epochPattern.MatchString(value) {
+			format = "epoch"
+		}
+	}
+
+	switch format {
+	case "epoch":
+		seconds, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("cannot parse %q as epoch", value)
+		}
+		// split first, a float64 of nanoseconds since 1970 is not exact
+		whole, fraction := math.Modf(seconds)
+		return time.Unix(int64(whole), int64(math.Round(fraction*float64(time.Second)))), nil
+	case "rfc3339":
+		t, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			// also accept a space between the date and the time
+			t, err = time.Parse(time.RFC3339Nano, strings.Replace(value, " ", "T", 1))
+		}
+		if err != nil {
+			return time.Time{}, fmt.Errorf("cannot parse %q as RFC 3339", value)
+		}
+		return t, nil
+	}
+	t, err := time.ParseInLocation(format, value, loc)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("cannot parse %q with layout %q", value, format)
+	}
+	return t, nil
+}
+
+// contentTime reads the time written in the file: the value of a JSON key,
+// the first capture group of a regexp or the whole content.
+type contentTime struct {
+	jsonKey  string
+	regex    *regexp.Regexp
+	format   string
+	location *time.Location
+	maxBytes int64
+}
+
+func (c contentTime) extract(data []byte) (string, error) {
+	switch {
+	case c.jsonKey != "":
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		var doc interface{}
+		if err := decoder.Decode(&doc); err != nil {
+			return "", fmt.Errorf("invalid JSON: %s", err)
+		}
+		value, err := lookupKey(doc, c.jsonKey)
+		if err != nil {
+			return "", err
+		}
+		switch v := value.(type) {
+		case string:
+			return v, nil
+		case json.Number:
+			return v.String(), nil
+		}
+		return "", fmt.Errorf("key %q is not a string or a number", c.jsonKey)
+	case c.regex != nil:
+		match := c.regex.FindSubmatch(data)
+		if match == nil {
+			return "", fmt.Errorf("no match for %q", c.regex)
+		}
+		if len(match) > 1 {
+			return string(match[1]), nil
+		}
+		return string(match[0]), nil
+	}
+	return string(data), nil
+}
+
+func (c contentTime) source() timeSource {
+	return func(path string, stat os.FileInfo) (time.Time, error) {
+		data, err := readHead(path, c.maxBytes)
+		if err != nil {
+			return time.Time{}, err
+		}
+		value, err := c.extract(data)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("cannot read time from %s: %s", path, err)
+		}
+		t, err := parseTime(value, c.format, c.location)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("cannot read time from %s: %s", path, err)
+		}
+		return t, nil
+	}
+}
@@ -0,0 +1,84 @@
+package checkfileage
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestContentTime(t *testing.T) {
+	paris, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := time.Date(2026, 10, 18, 8, 15, 32, 0, time.UTC)
+	backup := `{"backup": {"finished_at": "2026-10-18T10:15:32+02:00", "epoch": 1792311332, "ok": true},
+		"runs": [{"end": 1792311000}, {"end": "2026-10-18 08:15:32Z", "steps": [[1, 1792311332.5]]}]}`
+
+	tests := []struct {
+		name    string
+		content string
+		time    contentTime
+		want    time.Time
+		err     string
+	}{
+		{name: "nested key", content: backup, time: contentTime{jsonKey: "backup.finished_at", format: "auto"}, want: want},
+		{name: "$ prefix", content: backup, time: contentTime{jsonKey: "$.backup.finished_at", format: "auto"}, want: want},
+		{name: "number value", content: backup, time: contentTime{jsonKey: "backup.epoch", format: "auto"}, want: want},
+		{name: "index", content: backup, time: contentTime{jsonKey: "runs[1].end", format: "auto"}, want: want},
+		{name: "nested indexes", content: backup, time: contentTime{jsonKey: "runs[1].steps[0][1]", format: "epoch"}, want: want.Add(500 * time.Millisecond)},
+		{name: "missing key", content: backup, time: contentTime{jsonKey: "backup.started_at", format: "auto"}, err: `no key "backup.started_at"`},
+		{name: "index out of range", content: backup, time: contentTime{jsonKey: "runs[2].end", format: "auto"}, err: `no key "runs[2].end"`},
+		{name: "index of an object", content: backup, time: contentTime{jsonKey: "backup[0]", format: "auto"}, err: `no key "backup[0]"`},
+		{name: "boolean value", content: backup, time: contentTime{jsonKey: "backup.ok", format: "auto"}, err: `key "backup.ok" is not a string or a number`},
+		{name: "invalid JSON", content: "finished_at=1792311332", time: contentTime{jsonKey: "finished_at", format: "auto"}, err: "invalid JSON"},
+
+		{name: "regex capture", content: "# backup finished 18/10/2026 10:15:32 ok\n", time: contentTime{regex: regexp.MustCompile(`finished (\S+ \S+)`), format: "02/01/2006 15:04:05", location: paris}, want: want},
+		{name: "regex without group", content: "last: 1792311332\n", time: contentTime{regex: regexp.MustCompile(`\d{10}`), format: "auto"}, want: want},
+		{name: "regex no match", content: "backup failed\n", time: contentTime{regex: regexp.MustCompile(`finished (\S+)`), format: "auto"}, err: `no match for "finished (\\S+)"`},
+
+		{name: "auto epoch", content: "1792311332\n", time: contentTime{format: "auto"}, want: want},
+		{name: "auto epoch fraction", content: "1792311332.25\n", time: contentTime{format: "auto"}, want: want.Add(250 * time.Millisecond)},
+		{name: "auto rfc3339", content: "2026-10-18T08:15:32Z\n", time: contentTime{format: "auto"}, want: want},
+		{name: "auto rfc3339 with space", content: "2026-10-18 10:15:32+02:00\n", time: contentTime{format: "auto"}, want: want},
+		{name: "auto garbage", content: "yesterday\n", time: contentTime{format: "auto"}, err: `cannot parse "yesterday" as RFC 3339`},
+		{name: "epoch format rejects rfc3339", content: "2026-10-18T08:15:32Z", time: contentTime{format: "epoch"}, err: `cannot parse "2026-10-18T08:15:32Z" as epoch`},
+		{name: "layout in zone", content: "2026-10-18 10:15:32", time: contentTime{format: "2006-01-02 15:04:05", location: paris}, want: want},
+		{name: "layout mismatch", content: "18/10/2026", time: contentTime{format: "2006-01-02", location: paris}, err: `cannot parse "18/10/2026" with layout "2006-01-02"`},
+
+		{name: "larger than max bytes", content: strings.Repeat(" ", 100) + "1792311332", time: contentTime{format: "auto", maxBytes: 64}, err: "is larger than --max-bytes"},
+	}
+
+	dir := t.TempDir()
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, strings.Repeat("f", i+1))
+			if err := os.WriteFile(path, []byte(tt.content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if tt.time.maxBytes == 0 {
+				tt.time.maxBytes = 1 << 20
+			}
+			stat, err := os.Stat(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := tt.time.source()(path, stat)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("got error %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package checkfileage
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/mackerelio/checkers"
+)
+
+// shortDuration formats seconds the way durations are given on the
+// command line, e.g. 15m or 1d12h.
+func shortDuration(seconds int64) string {
+	if seconds == 0 {
+		return "0s"
+	}
+	var result string
+	for _, unit := range []struct {
+		suffix  string
+		seconds int64
+	}{{"d", 86400}, {"h", 3600}, {"m", 60}, {"s", 1}} {
+		if seconds >= unit.seconds {
+			result += strconv.FormatInt(seconds/unit.seconds, 10) + unit.suffix
+			seconds %= unit.seconds
+		}
+	}
+	return result
+}
+
+// counter counts the files older and larger than the given values, zero
+// meaning no condition.
+type counter struct {
+	olderThan  int64
+	largerThan int64
+	warning    *int
+	critical   *int
+}
+
+func (c counter) matches(f fileInfo) bool {
+	return (c.olderThan == 0 || f.age > c.olderThan) && (c.largerThan == 0 || f.size > c.largerThan)
+}
+
+func (c counter) describe() string {
+	var conditions []string
+	if c.olderThan > 0 {
+		conditions = append(conditions, "older than "+shortDuration(c.olderThan))
+	}
+	if c.largerThan > 0 {
+		conditions = append(conditions, fmt.Sprintf("larger than %d bytes", c.largerThan))
+	}
+	return strings.Join(conditions, " and ")
+}
+
+func (c counter) check(files []fileInfo, location string) *checkers.Checker {
+	var matching []fileInfo
+	for _, f := range files {
+		if c.matches(f) {
+			matching = append(matching, f)
+		}
+	}
+	count := len(matching)
+
+	result := checkers.OK
+	if c.warning != nil && count > *c.warning {
+		result = checkers.WARNING
+	}
+	if c.critical != nil && count > *c.critical {
+		result = checkers.CRITICAL
+	}
+
+	msg := fmt.Sprintf("%d files", count)
+	if conditions := c.describe(); conditions != "" {
+		msg += " " + conditions
+	}
+	msg += " in " + location
+
+	// files are sorted from the newest, list the oldest
+	for i := 0; i < count && i < maxListedFiles; i++ {
+		msg += "\n" + matching[count-1-i].String()
+	}
+	if count > maxListedFiles {
+		msg += fmt.Sprintf("\n... and %d more", count-maxListedFiles)
+	}
+	return checkers.NewChecker(result, msg)
+}
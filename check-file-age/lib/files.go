@@ -0,0 +1,269 @@
+package checkfileage
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mackerelio/checkers"
+)
+
+// maxListedFiles caps the number of files detailed in --select all mode.
+const maxListedFiles = 10
+
+type fileInfo struct {
+	path string
+	age  int64
+	size int64
+}
+
+// walker lists the regular files of a directory, down to maxDepth levels
+// when recursive (0 meaning no limit). Base names must match one of the
+// include patterns, if any, and none of the exclude ones, which also prune
+// directories.
+type walker struct {
+	recursive bool
+	maxDepth  int
+	include   []string
+	exclude   []string
+}
+
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := filepath.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (w walker) accept(name string) bool {
+	return (len(w.include) == 0 || matchAny(w.include, name)) && !matchAny(w.exclude, name)
+}
+
+func (w walker) walk(root string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			if path == root {
+				return nil
+			}
+			rel, err := filepath.Rel(root, path)
+			if err != nil {
+				return err
+			}
+			depth := strings.Count(rel, string(filepath.Separator)) + 1
+			if !w.recursive || (w.maxDepth > 0 && depth >= w.maxDepth) || matchAny(w.exclude, entry.Name()) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if entry.Type().IsRegular() && w.accept(entry.Name()) {
+			files = append(files, path)
+		}
+		return nil
+	})
+	return files, err
+}
+
+// walkLinked walks root, which may be a symlink to a directory, and returns
+// the files under the path as given rather than under its target.
+func (w walker) walkLinked(root string) ([]string, error) {
+	target, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return nil, err
+	}
+	files, err := w.walk(target)
+	if err != nil {
+		return nil, err
+	}
+	for i, file := range files {
+		rel, err := filepath.Rel(target, file)
+		if err != nil {
+			return nil, err
+		}
+		files[i] = filepath.Join(root, rel)
+	}
+	return files, nil
+}
+
+// findFiles returns the regular files designated by pattern: the file
+// itself, the files of a directory or the matches of a glob.
+func (w walker) findFiles(pattern string) ([]string, error) {
+	stat, err := os.Stat(pattern)
+	if err == nil {
+		if !stat.IsDir() {
+			return []string{pattern}, nil
+		}
+		return w.walkLinked(pattern)
+	}
+	if !strings.ContainsAny(pattern, `*?[\`) {
+		return nil, err
+	}
+
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, match := range matches {
+		if stat, err := os.Stat(match); err == nil && stat.Mode().IsRegular() && w.accept(filepath.Base(match)) {
+			files = append(files, match)
+		}
+	}
+	return files, nil
+}
+
+// timeSource returns the time the age of a file is computed from.
+type timeSource func(path string, stat os.FileInfo) (time.Time, error)
+
+// fieldTime reads the timestamp field of the file (mtime, ctime...).
+func fieldTime(field string) timeSource {
+	return func(path string, stat os.FileInfo) (time.Time, error) {
+		if field == "mtime" {
+			return stat.ModTime(), nil
+		}
+		return fileTime(path, field)
+	}
+}
+
+// statFiles reads the size and the age of paths.
+func statFiles(paths []string, now time.Time, timeOf timeSource) ([]fileInfo, error) {
+	files := make([]fileInfo, 0, len(paths))
+	for _, path := range paths {
+		var t time.Time
+		stat, err := os.Stat(path)
+		if err == nil {
+			t, err = timeOf(path, stat)
+		}
+		if errors.Is(err, fs.ErrNotExist) {
+			// removed since it was listed, e.g. a spool being drained
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, fileInfo{path: path, age: now.Unix() - t.Unix(), size: stat.Size()})
+	}
+	return files, nil
+}
+
+// selectFiles keeps the newest or the oldest file, or all of them.
+func selectFiles(files []fileInfo, selection string) []fileInfo {
+	sort.SliceStable(files, func(i, j int) bool {
+		if files[i].age != files[j].age {
+			return files[i].age < files[j].age
+		}
+		return files[i].path < files[j].path
+	})
+	switch selection {
+	case "newest":
+		return files[:1]
+	case "oldest":
+		return files[len(files)-1:]
+	}
+	return files
+}
+
+func (m monitor) check(f fileInfo) checkers.Status {
+	if m.CheckCritical(f.age, f.size) {
+		return checkers.CRITICAL
+	}
+	if m.CheckWarning(f.age, f.size) {
+		return checkers.WARNING
+	}
+	return checkers.OK
+}
+
+// evaluate returns the status of a file and the message describing it.
+func (m monitor) evaluate(f fileInfo) (checkers.Status, string) {
+	status := m.check(f)
+	msg := f.String()
+	if m.content != nil {
+		contentStatus, reason := m.content.check(f.path)
+		status = worse(status, contentStatus)
+		if reason != "" {
+			msg += " " + reason
+		}
+	}
+	return status, msg
+}
+
+// severity orders statuses from OK to CRITICAL: a file known to be over a
+// threshold is worse than one that could not be fully checked.
+func severity(status checkers.Status) int {
+	switch status {
+	case checkers.OK:
+		return 0
+	case checkers.WARNING:
+		return 1
+	case checkers.UNKNOWN:
+		return 2
+	default:
+		return 3
+	}
+}
+
+func worse(a, b checkers.Status) checkers.Status {
+	if severity(b) > severity(a) {
+		return b
+	}
+	return a
+}
+
+func (f fileInfo) String() string {
+	duration := strings.TrimSpace(secondsToHuman(f.age))
+	return fmt.Sprintf("%s is %d seconds old (%s) and %d bytes.", f.path, f.age, duration, f.size)
+}
+
+// checkFiles evaluates every file and lists the ones over the thresholds,
+// the worst first.
+func checkFiles(m *monitor, files []fileInfo) *checkers.Checker {
+	type offender struct {
+		fileInfo
+		status  checkers.Status
+		message string
+	}
+
+	result := checkers.OK
+	counts := map[checkers.Status]int{}
+	var offenders []offender
+	for _, f := range files {
+		status, message := m.evaluate(f)
+		counts[status]++
+		if status != checkers.OK {
+			offenders = append(offenders, offender{f, status, message})
+		}
+		result = worse(result, status)
+	}
+
+	sort.SliceStable(offenders, func(i, j int) bool {
+		if offenders[i].status != offenders[j].status {
+			return severity(offenders[i].status) > severity(offenders[j].status)
+		}
+		return offenders[i].age > offenders[j].age
+	})
+
+	msg := fmt.Sprintf("%d files", len(files))
+	for _, status := range []checkers.Status{checkers.CRITICAL, checkers.UNKNOWN, checkers.WARNING, checkers.OK} {
+		if counts[status] > 0 {
+			msg += fmt.Sprintf(" - %s: %d", status, counts[status])
+		}
+	}
+	for i, o := range offenders {
+		if i == maxListedFiles {
+			msg += fmt.Sprintf("\n... and %d more", len(offenders)-maxListedFiles)
+			break
+		}
+		msg += fmt.Sprintf("\n%s: %s", o.status, o.message)
+	}
+	return checkers.NewChecker(result, msg)
+}
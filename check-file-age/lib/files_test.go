@@ -0,0 +1,49 @@
+package checkfileage
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestFindFilesSymlinkedDirectory(t *testing.T) {
+	dir := t.TempDir()
+	spool := filepath.Join(dir, "spool")
+	for _, name := range []string{"spool/a", "spool/b", "spool/sub/c"} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("x"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	link := filepath.Join(dir, "spoollink")
+	if err := os.Symlink(spool, link); err != nil {
+		t.Skip(err)
+	}
+
+	tests := []struct {
+		walker walker
+		want   []string
+	}{
+		{walker{}, []string{"a", "b"}},
+		{walker{recursive: true}, []string{"a", "b", "sub/c"}},
+	}
+	for _, tt := range tests {
+		got, err := tt.walker.findFiles(link)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sort.Strings(got)
+		want := make([]string, len(tt.want))
+		for i, name := range tt.want {
+			want[i] = filepath.Join(link, filepath.FromSlash(name))
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%+v: got %q, want %q", tt.walker, got, want)
+		}
+	}
+}
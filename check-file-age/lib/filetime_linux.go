@@ -0,0 +1,52 @@
+//go:build linux
+
+package checkfileage
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+var statxMasks = map[string]int{
+	"mtime": unix.STATX_MTIME,
+	"ctime": unix.STATX_CTIME,
+	"atime": unix.STATX_ATIME,
+	"btime": unix.STATX_BTIME,
+}
+
+// fileTime reads the requested timestamp with statx, which tells whether
+// the filesystem provides it (birth time often is not).
+func fileTime(path, field string) (time.Time, error) {
+	mask, ok := statxMasks[field]
+	if !ok {
+		return time.Time{}, fmt.Errorf("unknown time field %s", field)
+	}
+
+	var stx unix.Statx_t
+	if err := unix.Statx(unix.AT_FDCWD, path, 0, mask, &stx); err != nil {
+		if errors.Is(err, unix.ENOSYS) {
+			return time.Time{}, fmt.Errorf("cannot read %s of %s: statx is not supported by this kernel", field, path)
+		}
+		return time.Time{}, &os.PathError{Op: "statx", Path: path, Err: err}
+	}
+	if stx.Mask&uint32(mask) == 0 {
+		return time.Time{}, fmt.Errorf("cannot read %s of %s: not provided by the filesystem", field, path)
+	}
+
+	var ts unix.StatxTimestamp
+	switch field {
+	case "mtime":
+		ts = stx.Mtime
+	case "ctime":
+		ts = stx.Ctime
+	case "atime":
+		ts = stx.Atime
+	case "btime":
+		ts = stx.Btime
+	}
+	return time.Unix(ts.Sec, int64(ts.Nsec)), nil
+}
@@ -0,0 +1,21 @@
+//go:build !linux
+
+package checkfileage
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// fileTime only knows the modification time outside Linux.
+func fileTime(path, field string) (time.Time, error) {
+	if field != "mtime" {
+		return time.Time{}, fmt.Errorf("--time-field %s is only supported on Linux", field)
+	}
+	stat, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return stat.ModTime(), nil
+}
@@ -0,0 +1,15 @@
+//go:build !windows
+
+package checkfileage
+
+import (
+	"os"
+	"syscall"
+)
+
+func inode(stat os.FileInfo) uint64 {
+	if st, ok := stat.Sys().(*syscall.Stat_t); ok {
+		return uint64(st.Ino)
+	}
+	return 0
+}
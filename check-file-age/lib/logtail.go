@@ -0,0 +1,216 @@
+package checkfileage
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// maxShownLineLength truncates the matching lines quoted in the output.
+const maxShownLineLength = 200
+
+// logPosition is where the previous run stopped reading a log file.
+type logPosition struct {
+	Inode  uint64 `json:"inode"`
+	Offset int64  `json:"offset"`
+}
+
+// logState maps log file paths to their position.
+type logState map[string]logPosition
+
+func loadLogState(file string) (logState, error) {
+	state := logState{}
+	data, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("%s: %s", file, err)
+	}
+	return state, nil
+}
+
+// save replaces the state file atomically.
+func (state logState) save(file string) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(file), filepath.Base(file)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), file)
+}
+
+// findRotated looks for the file the log was rotated to, e.g. app.log.1,
+// next to it by its inode.
+func findRotated(path string, ino uint64) string {
+	matches, _ := filepath.Glob(path + "?*")
+	for _, match := range matches {
+		if stat, err := os.Stat(match); err == nil && inode(stat) == ino {
+			return match
+		}
+	}
+	return ""
+}
+
+// logScanner counts the complete lines appended to log files that match
+// the critical or the warning regexps, keeping the first ones.
+type logScanner struct {
+	critical []*regexp.Regexp
+	warning  []*regexp.Regexp
+	maxShown int
+
+	lines         int
+	criticalCount int
+	warningCount  int
+	shown         []string
+}
+
+func (s *logScanner) line(text string) {
+	s.lines++
+	var label string
+	switch {
+	case matchesAny(s.critical, text):
+		s.criticalCount++
+		label = "CRITICAL"
+	case matchesAny(s.warning, text):
+		s.warningCount++
+		label = "WARNING"
+	default:
+		return
+	}
+	if len(s.shown) < s.maxShown {
+		if len(text) > maxShownLineLength {
+			text = text[:maxShownLineLength] + "..."
+		}
+		s.shown = append(s.shown, label+": "+text)
+	}
+}
+
+func matchesAny(regexps []*regexp.Regexp, text string) bool {
+	for _, re := range regexps {
+		if re.MatchString(text) {
+			return true
+		}
+	}
+	return false
+}
+
+// scan reads the complete lines of path from offset and returns the offset
+// after the last one; a line still being written is left for the next run.
+func (s *logScanner) scan(path string, offset int64) (int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return offset, err
+	}
+	defer f.Close()
+
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return offset, err
+	}
+	reader := bufio.NewReader(f)
+	for {
+		text, err := reader.ReadString('\n')
+		if err == io.EOF {
+			return offset, nil
+		}
+		if err != nil {
+			return offset, err
+		}
+		offset += int64(len(text))
+		s.line(strings.TrimRight(text, "\r\n"))
+	}
+}
+
+// lastLineEnd returns the offset after the last newline among the first
+// size bytes of path, 0 when there is none.
+func lastLineEnd(path string, size int64) (int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	for pos := size; pos > 0; {
+		n := int64(tailChunkSize)
+		if pos < n {
+			n = pos
+		}
+		pos -= n
+		chunk := make([]byte, n)
+		if _, err := f.ReadAt(chunk, pos); err != nil && err != io.EOF {
+			return 0, err
+		}
+		if i := bytes.LastIndexByte(chunk, '\n'); i >= 0 {
+			return pos + int64(i) + 1, nil
+		}
+	}
+	return 0, nil
+}
+
+// tailLog scans what was appended to path since the previous run. The
+// first run only records the end of the file. A new inode means the log
+// was rotated: the end of the rotated file is read when it can be found,
+// then the new file from its start. A file shorter than the recorded
+// offset was truncated and is read from its start.
+func tailLog(path string, stat os.FileInfo, state logState, s *logScanner) (string, error) {
+	ino := inode(stat)
+	previous, known := state[path]
+	if !known {
+		// a line still being written is scanned in full by the next run
+		end, err := lastLineEnd(path, stat.Size())
+		if err != nil {
+			return "", err
+		}
+		state[path] = logPosition{Inode: ino, Offset: end}
+		return fmt.Sprintf("first run, starting at offset %d", end), nil
+	}
+
+	var notes []string
+	offset := previous.Offset
+	switch {
+	case previous.Inode != ino:
+		if rotated := findRotated(path, previous.Inode); rotated != "" {
+			if _, err := s.scan(rotated, previous.Offset); err != nil {
+				return "", err
+			}
+			notes = append(notes, "rotated to "+rotated)
+		} else {
+			notes = append(notes, "rotated")
+		}
+		offset = 0
+	case stat.Size() < previous.Offset:
+		notes = append(notes, "truncated")
+		offset = 0
+	}
+
+	offset, err := s.scan(path, offset)
+	if err != nil {
+		return "", err
+	}
+	state[path] = logPosition{Inode: ino, Offset: offset}
+	return strings.Join(notes, ", "), nil
+}
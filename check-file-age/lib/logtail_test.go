@@ -0,0 +1,51 @@
+package checkfileage
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+)
+
+func TestTailLogPartialLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	state := logState{}
+	appendLog := func(text string) {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		if _, err := f.WriteString(text); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tail := func() *logScanner {
+		stat, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s := &logScanner{critical: []*regexp.Regexp{regexp.MustCompile("ERROR")}, maxShown: 10}
+		if _, err := tailLog(path, stat, state, s); err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+
+	// the first run stops before the line being written
+	appendLog("ERROR before\nERROR parti")
+	if s := tail(); s.lines != 0 || state[path].Offset != 13 {
+		t.Fatalf("first run scanned %d lines up to %d, want 0 up to 13", s.lines, state[path].Offset)
+	}
+
+	appendLog("al\nok\nERROR pending")
+	s := tail()
+	if s.lines != 2 || s.criticalCount != 1 || s.shown[0] != "CRITICAL: ERROR partial" {
+		t.Fatalf("got %d lines, %d matches %q, want the completed line and ok", s.lines, s.criticalCount, s.shown)
+	}
+
+	appendLog("\n")
+	if s := tail(); s.lines != 1 || s.criticalCount != 1 {
+		t.Fatalf("got %d lines and %d matches, want the pending line", s.lines, s.criticalCount)
+	}
+}
@@ -0,0 +1,76 @@
+package checkfileage
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/dustin/go-humanize"
+)
+
+// ageFlag is an age in seconds, given as a plain number of seconds or as a
+// duration such as 90m, 36h, 7d or 1d12h.
+type ageFlag int64
+
+// sizeFlag is a size in bytes, given as a plain number of bytes or with a
+// unit such as 10MB or 1.5GiB.
+type sizeFlag int64
+
+var durationUnits = map[string]float64{
+	"s": 1, "sec": 1, "second": 1, "seconds": 1,
+	"m": 60, "min": 60, "minute": 60, "minutes": 60,
+	"h": 3600, "hr": 3600, "hour": 3600, "hours": 3600,
+	"d": 86400, "day": 86400, "days": 86400,
+	"w": 604800, "week": 604800, "weeks": 604800,
+}
+
+var durationPart = regexp.MustCompile(`^(\d+(?:\.\d+)?)\s*([a-z]+)\s*`)
+
+// UnmarshalFlag implements flags.Unmarshaler.
+func (a *ageFlag) UnmarshalFlag(value string) error {
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		*a = ageFlag(seconds)
+		return nil
+	}
+
+	rest := strings.ToLower(strings.TrimSpace(value))
+	if rest == "" {
+		return fmt.Errorf("invalid duration %q", value)
+	}
+	var seconds float64
+	for rest != "" {
+		match := durationPart.FindStringSubmatch(rest)
+		if match == nil {
+			return fmt.Errorf("invalid duration %q", value)
+		}
+		unit, ok := durationUnits[match[2]]
+		if !ok {
+			return fmt.Errorf("unknown unit %q in duration %q", match[2], value)
+		}
+		number, _ := strconv.ParseFloat(match[1], 64)
+		seconds += number * unit
+		rest = rest[len(match[0]):]
+	}
+	*a = ageFlag(math.Round(seconds))
+	return nil
+}
+
+// UnmarshalFlag implements flags.Unmarshaler.
+func (s *sizeFlag) UnmarshalFlag(value string) error {
+	if bytes, err := strconv.ParseInt(value, 10, 64); err == nil {
+		*s = sizeFlag(bytes)
+		return nil
+	}
+
+	bytes, err := humanize.ParseBytes(value)
+	if err != nil {
+		return fmt.Errorf("invalid size %q", value)
+	}
+	if bytes > math.MaxInt64 {
+		return fmt.Errorf("size %q is too large", value)
+	}
+	*s = sizeFlag(bytes)
+	return nil
+}
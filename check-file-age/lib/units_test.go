@@ -0,0 +1,86 @@
+package checkfileage
+
+import "testing"
+
+func TestAgeFlag(t *testing.T) {
+	tests := []struct {
+		value string
+		want  int64
+		err   string
+	}{
+		// plain seconds, as before durations were accepted
+		{value: "0", want: 0},
+		{value: "240", want: 240},
+		{value: "86400", want: 86400},
+
+		{value: "30s", want: 30},
+		{value: "90m", want: 5400},
+		{value: "36h", want: 129600},
+		{value: "7d", want: 604800},
+		{value: "2w", want: 1209600},
+		{value: "1d12h", want: 129600},
+		{value: "1h30m15s", want: 5415},
+		{value: "1.5h", want: 5400},
+		{value: "2 hours", want: 7200},
+		{value: "10min", want: 600},
+		{value: "36H", want: 129600},
+
+		{value: "", err: `invalid duration ""`},
+		{value: "300ms", err: `unknown unit "ms" in duration "300ms"`},
+		{value: "5y", err: `unknown unit "y" in duration "5y"`},
+		{value: "-5m", err: `invalid duration "-5m"`},
+		{value: "1h-5m", err: `invalid duration "1h-5m"`},
+		{value: "h", err: `invalid duration "h"`},
+		{value: "1.5", err: `invalid duration "1.5"`},
+	}
+	for _, tt := range tests {
+		var got ageFlag
+		err := got.UnmarshalFlag(tt.value)
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("%q: got error %v, want %q", tt.value, err, tt.err)
+			}
+			continue
+		}
+		if err != nil || int64(got) != tt.want {
+			t.Errorf("%q: got %d (%v), want %d", tt.value, got, err, tt.want)
+		}
+	}
+}
+
+func TestSizeFlag(t *testing.T) {
+	tests := []struct {
+		value string
+		want  int64
+		err   string
+	}{
+		// plain bytes, as before units were accepted
+		{value: "0", want: 0},
+		{value: "1024", want: 1024},
+
+		{value: "10kB", want: 10000},
+		{value: "10KiB", want: 10240},
+		{value: "10MB", want: 10000000},
+		{value: "1.5GiB", want: 1610612736},
+		{value: "2 GB", want: 2000000000},
+
+		{value: "", err: `invalid size ""`},
+		{value: "ten", err: `invalid size "ten"`},
+		{value: "10XB", err: `invalid size "10XB"`},
+		{value: "9EiB", err: `size "9EiB" is too large`},
+		{value: "16EiB", err: `invalid size "16EiB"`},
+	}
+	for _, tt := range tests {
+		var got sizeFlag
+		err := got.UnmarshalFlag(tt.value)
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("%q: got error %v, want %q", tt.value, err, tt.err)
+			}
+			continue
+		}
+		if err != nil || int64(got) != tt.want {
+			t.Errorf("%q: got %d (%v), want %d", tt.value, got, err, tt.want)
+		}
+	}
+}